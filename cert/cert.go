@@ -0,0 +1,146 @@
+// Package cert generates certificates for development and tests, and loads the
+// gRPC transport credentials of the server and the client for plaintext,
+// server-side TLS or mutual TLS.
+package cert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"time"
+)
+
+// KeyPair is a PEM-encoded certificate and its private key
+type KeyPair struct {
+	CertPEM []byte
+	KeyPEM  []byte
+
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// WriteFiles writes the certificate and the private key, the key is only readable by its owner
+func (pair *KeyPair) WriteFiles(certFile string, keyFile string) error {
+	if err := ioutil.WriteFile(certFile, pair.CertPEM, 0644); err != nil {
+		return fmt.Errorf("cannot write certificate: %w", err)
+	}
+	if err := ioutil.WriteFile(keyFile, pair.KeyPEM, 0600); err != nil {
+		return fmt.Errorf("cannot write private key: %w", err)
+	}
+	return nil
+}
+
+// Authority is a certificate authority that signs server and client certificates
+type Authority struct {
+	KeyPair
+	validity time.Duration
+}
+
+// NewAuthority generates a self-signed CA, the certificates it issues have the same validity
+func NewAuthority(commonName string, validity time.Duration) (*Authority, error) {
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: commonName, Organization: []string{"PC Book"}},
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	pair, err := newKeyPair(template, nil, validity)
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate CA: %w", err)
+	}
+
+	return &Authority{KeyPair: *pair, validity: validity}, nil
+}
+
+// IssueServer issues a certificate for a server reachable with the given DNS names or IP addresses
+func (ca *Authority) IssueServer(hosts []string) (*KeyPair, error) {
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("cannot issue server certificate without host")
+	}
+
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: hosts[0], Organization: []string{"PC Book"}},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	pair, err := newKeyPair(template, &ca.KeyPair, ca.validity)
+	if err != nil {
+		return nil, fmt.Errorf("cannot issue server certificate: %w", err)
+	}
+	return pair, nil
+}
+
+// IssueClient issues a certificate for a client identified by commonName
+func (ca *Authority) IssueClient(commonName string) (*KeyPair, error) {
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: commonName, Organization: []string{"PC Book"}},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	pair, err := newKeyPair(template, &ca.KeyPair, ca.validity)
+	if err != nil {
+		return nil, fmt.Errorf("cannot issue client certificate: %w", err)
+	}
+	return pair, nil
+}
+
+// newKeyPair generates a key and a certificate from template, signed by parent or self-signed if parent is nil
+func newKeyPair(template *x509.Certificate, parent *KeyPair, validity time.Duration) (*KeyPair, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template.SerialNumber = serialNumber
+	template.NotBefore = now.Add(-time.Minute) // 容忍少量时钟偏差
+	template.NotAfter = now.Add(validity)
+
+	signerCert, signerKey := template, key
+	if parent != nil {
+		signerCert, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signerCert, &key.PublicKey, signerKey)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyPair{
+		CertPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		KeyPEM:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+		cert:    cert,
+		key:     key,
+	}, nil
+}
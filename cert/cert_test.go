@@ -0,0 +1,88 @@
+package cert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func parseCertificate(t *testing.T, pemData []byte) *x509.Certificate {
+	block, _ := pem.Decode(pemData)
+	require.NotNil(t, block)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	return cert
+}
+
+func TestAuthorityIssue(t *testing.T) {
+	t.Parallel()
+
+	ca, err := NewAuthority("test CA", time.Hour)
+	require.NoError(t, err)
+	caCert := parseCertificate(t, ca.CertPEM)
+	require.True(t, caCert.IsCA)
+	require.WithinDuration(t, time.Now().Add(time.Hour), caCert.NotAfter, time.Minute)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+
+	serverPair, err := ca.IssueServer([]string{"localhost", "127.0.0.1"})
+	require.NoError(t, err)
+	serverCert := parseCertificate(t, serverPair.CertPEM)
+	require.Equal(t, []string{"localhost"}, serverCert.DNSNames)
+	require.True(t, serverCert.IPAddresses[0].Equal(net.ParseIP("127.0.0.1")))
+	_, err = serverCert.Verify(x509.VerifyOptions{
+		DNSName:   "localhost",
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	require.NoError(t, err)
+
+	clientPair, err := ca.IssueClient("client1")
+	require.NoError(t, err)
+	clientCert := parseCertificate(t, clientPair.CertPEM)
+	require.Equal(t, "client1", clientCert.Subject.CommonName)
+	_, err = clientCert.Verify(x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	require.NoError(t, err)
+
+	// 客户端证书不能用作服务端证书
+	_, err = clientCert.Verify(x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	require.Error(t, err)
+
+	_, err = ca.IssueServer(nil)
+	require.Error(t, err)
+}
+
+func TestKeyPairWriteFiles(t *testing.T) {
+	t.Parallel()
+
+	ca, err := NewAuthority("test CA", time.Hour)
+	require.NoError(t, err)
+	pair, err := ca.IssueClient("client1")
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "client-cert.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+	require.NoError(t, pair.WriteFiles(certFile, keyFile))
+
+	info, err := os.Stat(keyFile)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	_, err = tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+}
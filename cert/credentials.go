@@ -0,0 +1,146 @@
+package cert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Mode is the transport security of a gRPC connection
+type Mode string
+
+// Modes of transport security
+const (
+	// Plaintext doesn't encrypt the connection
+	Plaintext Mode = "plaintext"
+	// TLS encrypts the connection and authenticates the server
+	TLS Mode = "tls"
+	// MutualTLS encrypts the connection and authenticates both the server and the client
+	MutualTLS Mode = "mtls"
+)
+
+// ParseMode parses a transport security mode: "plaintext", "tls" or "mtls"
+func ParseMode(s string) (Mode, error) {
+	switch mode := Mode(s); mode {
+	case Plaintext, TLS, MutualTLS:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown TLS mode %q, must be %s, %s or %s", s, Plaintext, TLS, MutualTLS)
+	}
+}
+
+// ServerConfig is the transport security of a server
+type ServerConfig struct {
+	Mode Mode
+	// CertFile and KeyFile are the PEM files of the server certificate, for TLS and MutualTLS
+	CertFile string
+	KeyFile  string
+	// ClientCAFile is the PEM file of the CA that signs the client certificates, for MutualTLS
+	ClientCAFile string
+}
+
+// Credentials loads the files of the config and returns the server transport credentials
+func (config ServerConfig) Credentials() (credentials.TransportCredentials, error) {
+	tlsConfig, err := config.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig == nil {
+		return insecure.NewCredentials(), nil
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// TLSConfig loads the files of the config and returns the server TLS config, or nil for Plaintext
+func (config ServerConfig) TLSConfig() (*tls.Config, error) {
+	if config.Mode == Plaintext {
+		return nil, nil
+	}
+
+	serverCert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load server certificate: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.NoClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	switch config.Mode {
+	case TLS:
+	case MutualTLS:
+		clientCAs, err := loadCertPool(config.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load client CA: %w", err)
+		}
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		tlsConfig.ClientCAs = clientCAs
+	default:
+		return nil, fmt.Errorf("unknown TLS mode %q", config.Mode)
+	}
+
+	return tlsConfig, nil
+}
+
+// ClientConfig is the transport security of a client
+type ClientConfig struct {
+	Mode Mode
+	// CAFile is the PEM file of the CA that signs the server certificate, for TLS and MutualTLS
+	CAFile string
+	// CertFile and KeyFile are the PEM files of the client certificate, for MutualTLS
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the host name checked against the server certificate if it is not empty
+	ServerName string
+}
+
+// Credentials loads the files of the config and returns the client transport credentials
+func (config ClientConfig) Credentials() (credentials.TransportCredentials, error) {
+	if config.Mode == Plaintext {
+		return insecure.NewCredentials(), nil
+	}
+
+	rootCAs, err := loadCertPool(config.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load server CA: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		RootCAs:    rootCAs,
+		ServerName: config.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	switch config.Mode {
+	case TLS:
+	case MutualTLS:
+		clientCert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	default:
+		return nil, fmt.Errorf("unknown TLS mode %q", config.Mode)
+	}
+
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+func loadCertPool(filename string) (*x509.CertPool, error) {
+	pemData, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemData) {
+		return nil, fmt.Errorf("no certificate found in %s", filename)
+	}
+	return pool, nil
+}
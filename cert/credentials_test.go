@@ -0,0 +1,238 @@
+package cert
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/Ruadgedy/pcbook/sample"
+	"github.com/Ruadgedy/pcbook/service"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testCertFiles are the certificate files of a CA, a server and a client it signs
+type testCertFiles struct {
+	caCert, serverCert, serverKey, clientCert, clientKey string
+}
+
+func writeTestCertFiles(t *testing.T) testCertFiles {
+	dir := t.TempDir()
+	files := testCertFiles{
+		caCert:     filepath.Join(dir, "ca-cert.pem"),
+		serverCert: filepath.Join(dir, "server-cert.pem"),
+		serverKey:  filepath.Join(dir, "server-key.pem"),
+		clientCert: filepath.Join(dir, "client-cert.pem"),
+		clientKey:  filepath.Join(dir, "client-key.pem"),
+	}
+
+	ca, err := NewAuthority("test CA", time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, ca.WriteFiles(files.caCert, filepath.Join(dir, "ca-key.pem")))
+
+	serverPair, err := ca.IssueServer([]string{"localhost", "127.0.0.1"})
+	require.NoError(t, err)
+	require.NoError(t, serverPair.WriteFiles(files.serverCert, files.serverKey))
+
+	clientPair, err := ca.IssueClient("client1")
+	require.NoError(t, err)
+	require.NoError(t, clientPair.WriteFiles(files.clientCert, files.clientKey))
+
+	return files
+}
+
+func startTestServer(t *testing.T, config ServerConfig) string {
+	creds, err := config.Credentials()
+	require.NoError(t, err)
+
+	grpcServer := grpc.NewServer(grpc.Creds(creds))
+	pb.RegisterLaptopServiceServer(grpcServer, service.NewLaptopServer(service.NewInMemoryLaptopStore(), nil, nil))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	return listener.Addr().String()
+}
+
+// createLaptop calls the server with the client config and returns the error of the call
+func createLaptop(t *testing.T, address string, config ClientConfig) error {
+	creds, err := config.Credentials()
+	require.NoError(t, err)
+
+	cc, err := grpc.Dial(address, grpc.WithTransportCredentials(creds))
+	require.NoError(t, err)
+	defer cc.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err = pb.NewLaptopServiceClient(cc).CreateLaptop(ctx, &pb.CreateLaptopRequest{Laptop: sample.NewLaptop()})
+	return err
+}
+
+func TestParseMode(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []string{"plaintext", "tls", "mtls"} {
+		mode, err := ParseMode(s)
+		require.NoError(t, err)
+		require.Equal(t, Mode(s), mode)
+	}
+
+	_, err := ParseMode("ssl")
+	require.Error(t, err)
+}
+
+func TestCredentials(t *testing.T) {
+	t.Parallel()
+
+	files := writeTestCertFiles(t)
+	otherFiles := writeTestCertFiles(t)
+
+	plaintextAddress := startTestServer(t, ServerConfig{Mode: Plaintext})
+	tlsAddress := startTestServer(t, ServerConfig{
+		Mode:     TLS,
+		CertFile: files.serverCert,
+		KeyFile:  files.serverKey,
+	})
+	mtlsAddress := startTestServer(t, ServerConfig{
+		Mode:         MutualTLS,
+		CertFile:     files.serverCert,
+		KeyFile:      files.serverKey,
+		ClientCAFile: files.caCert,
+	})
+
+	testCases := []struct {
+		name    string
+		address string
+		client  ClientConfig
+		code    codes.Code
+	}{
+		{
+			name:    "plaintext",
+			address: plaintextAddress,
+			client:  ClientConfig{Mode: Plaintext},
+			code:    codes.OK,
+		},
+		{
+			name:    "tls",
+			address: tlsAddress,
+			client:  ClientConfig{Mode: TLS, CAFile: files.caCert},
+			code:    codes.OK,
+		},
+		{
+			name:    "tls with server name",
+			address: tlsAddress,
+			client:  ClientConfig{Mode: TLS, CAFile: files.caCert, ServerName: "localhost"},
+			code:    codes.OK,
+		},
+		{
+			name:    "tls with wrong server name",
+			address: tlsAddress,
+			client:  ClientConfig{Mode: TLS, CAFile: files.caCert, ServerName: "example.com"},
+			code:    codes.Unavailable,
+		},
+		{
+			name:    "tls with untrusted server",
+			address: tlsAddress,
+			client:  ClientConfig{Mode: TLS, CAFile: otherFiles.caCert},
+			code:    codes.Unavailable,
+		},
+		{
+			name:    "plaintext client to tls server",
+			address: tlsAddress,
+			client:  ClientConfig{Mode: Plaintext},
+			code:    codes.Unavailable,
+		},
+		{
+			name:    "mtls",
+			address: mtlsAddress,
+			client: ClientConfig{
+				Mode:     MutualTLS,
+				CAFile:   files.caCert,
+				CertFile: files.clientCert,
+				KeyFile:  files.clientKey,
+			},
+			code: codes.OK,
+		},
+		{
+			name:    "mtls without client certificate",
+			address: mtlsAddress,
+			client:  ClientConfig{Mode: TLS, CAFile: files.caCert},
+			code:    codes.Unavailable,
+		},
+		{
+			name:    "mtls with client certificate of another CA",
+			address: mtlsAddress,
+			client: ClientConfig{
+				Mode:     MutualTLS,
+				CAFile:   files.caCert,
+				CertFile: otherFiles.clientCert,
+				KeyFile:  otherFiles.clientKey,
+			},
+			code: codes.Unavailable,
+		},
+		{
+			name:    "mtls with server certificate as client certificate",
+			address: mtlsAddress,
+			client: ClientConfig{
+				Mode:     MutualTLS,
+				CAFile:   files.caCert,
+				CertFile: files.serverCert,
+				KeyFile:  files.serverKey,
+			},
+			code: codes.Unavailable,
+		},
+		{
+			name:    "plaintext client to mtls server",
+			address: mtlsAddress,
+			client:  ClientConfig{Mode: Plaintext},
+			code:    codes.Unavailable,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := createLaptop(t, tc.address, tc.client)
+			require.Equal(t, tc.code, status.Code(err), "%v", err)
+		})
+	}
+}
+
+func TestCredentialsMissingFiles(t *testing.T) {
+	t.Parallel()
+
+	files := writeTestCertFiles(t)
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+
+	_, err := ServerConfig{Mode: TLS, CertFile: missing, KeyFile: files.serverKey}.Credentials()
+	require.Error(t, err)
+
+	_, err = ServerConfig{
+		Mode:         MutualTLS,
+		CertFile:     files.serverCert,
+		KeyFile:      files.serverKey,
+		ClientCAFile: missing,
+	}.Credentials()
+	require.Error(t, err)
+
+	// 私钥文件不是证书
+	_, err = ClientConfig{Mode: TLS, CAFile: files.clientKey}.Credentials()
+	require.Error(t, err)
+
+	_, err = ClientConfig{Mode: MutualTLS, CAFile: files.caCert, CertFile: missing, KeyFile: files.clientKey}.Credentials()
+	require.Error(t, err)
+
+	_, err = ServerConfig{Mode: "ssl"}.Credentials()
+	require.Error(t, err)
+}
@@ -0,0 +1,159 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AuthInterceptor is a client interceptor for authentication
+type AuthInterceptor struct {
+	authClient    *AuthClient
+	authMethods   map[string]bool
+	refreshBefore time.Duration
+
+	mutex       sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// NewAuthInterceptor returns a new auth interceptor that attaches an access token to the
+// methods in authMethods. It logs in again when the token expires in less than refreshBefore,
+// or when the server rejects it.
+func NewAuthInterceptor(
+	authClient *AuthClient,
+	authMethods map[string]bool,
+	refreshBefore time.Duration,
+) (*AuthInterceptor, error) {
+	interceptor := &AuthInterceptor{
+		authClient:    authClient,
+		authMethods:   authMethods,
+		refreshBefore: refreshBefore,
+	}
+
+	// 启动时先登录一次，尽早发现用户名密码错误
+	if _, err := interceptor.token(true); err != nil {
+		return nil, err
+	}
+
+	return interceptor, nil
+}
+
+// LaptopServiceAuthMethods returns the laptop service RPCs that need an access token
+func LaptopServiceAuthMethods() map[string]bool {
+	const laptopServicePath = "/techschool.pcbook.LaptopService/"
+
+	return map[string]bool{
+		laptopServicePath + "CreateLaptop": true,
+		laptopServicePath + "UpdateLaptop": true,
+		laptopServicePath + "UploadImage":  true,
+		laptopServicePath + "GetLaptop":    true,
+		laptopServicePath + "SearchLaptop": true,
+		laptopServicePath + "RateLaptop":   true,
+	}
+}
+
+// Unary returns a client interceptor to authenticate unary RPC
+func (interceptor *AuthInterceptor) Unary() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		log.Printf("--> unary interceptor: %s", method)
+
+		if !interceptor.authMethods[method] {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		authCtx, err := interceptor.attachToken(ctx, false)
+		if err != nil {
+			return err
+		}
+
+		err = invoker(authCtx, method, req, reply, cc, opts...)
+		if status.Code(err) != codes.Unauthenticated {
+			return err
+		}
+
+		// 令牌可能因服务端更换密钥而失效，重新登录后重试一次
+		authCtx, err = interceptor.attachToken(ctx, true)
+		if err != nil {
+			return err
+		}
+		return invoker(authCtx, method, req, reply, cc, opts...)
+	}
+}
+
+// Stream returns a client interceptor to authenticate stream RPC
+func (interceptor *AuthInterceptor) Stream() grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		log.Printf("--> stream interceptor: %s", method)
+
+		if !interceptor.authMethods[method] {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+
+		authCtx, err := interceptor.attachToken(ctx, false)
+		if err != nil {
+			return nil, err
+		}
+		return streamer(authCtx, desc, cc, method, opts...)
+	}
+}
+
+func (interceptor *AuthInterceptor) attachToken(ctx context.Context, refresh bool) (context.Context, error) {
+	accessToken, err := interceptor.token(refresh)
+	if err != nil {
+		return nil, err
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+accessToken), nil
+}
+
+// token returns the access token, it logs in again if refresh is true or the token is about to expire
+func (interceptor *AuthInterceptor) token(refresh bool) (string, error) {
+	interceptor.mutex.Lock()
+	defer interceptor.mutex.Unlock()
+
+	if !refresh && interceptor.accessToken != "" &&
+		(interceptor.expiresAt.IsZero() || time.Until(interceptor.expiresAt) > interceptor.refreshBefore) {
+		return interceptor.accessToken, nil
+	}
+
+	accessToken, err := interceptor.authClient.Login()
+	if err != nil {
+		return "", fmt.Errorf("cannot refresh access token: %w", err)
+	}
+
+	// 客户端没有签名密钥，只读取过期时间，令牌由服务端验证
+	claims := &jwt.RegisteredClaims{}
+	if _, _, err = new(jwt.Parser).ParseUnverified(accessToken, claims); err != nil {
+		return "", fmt.Errorf("cannot parse access token: %w", err)
+	}
+
+	interceptor.accessToken = accessToken
+	interceptor.expiresAt = time.Time{}
+	if claims.ExpiresAt != nil {
+		interceptor.expiresAt = claims.ExpiresAt.Time
+	}
+	log.Printf("token refreshed, expires at %v", interceptor.expiresAt)
+	return accessToken, nil
+}
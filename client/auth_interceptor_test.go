@@ -0,0 +1,141 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/Ruadgedy/pcbook/sample"
+	"github.com/Ruadgedy/pcbook/service"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// countingAuthServer counts the logins of the clients
+type countingAuthServer struct {
+	*service.AuthServer
+	logins int32
+}
+
+func (server *countingAuthServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	atomic.AddInt32(&server.logins, 1)
+	return server.AuthServer.Login(ctx, req)
+}
+
+func startTestAuthServer(t *testing.T) (*countingAuthServer, string) {
+	userStore := service.NewInMemoryUserStore()
+	err := service.SeedUsers(userStore, []service.UserConfig{
+		{Username: "admin1", Password: "secret", Role: service.RoleAdmin},
+	})
+	require.NoError(t, err)
+
+	jwtManager := service.NewJWTManager("secret key", time.Minute)
+	authServer, err := service.NewAuthServer(userStore, jwtManager)
+	require.NoError(t, err)
+	counting := &countingAuthServer{AuthServer: authServer}
+
+	interceptor := service.NewAuthInterceptor(jwtManager, service.LaptopServiceRoles(), service.PublicMethods())
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(interceptor.Unary()),
+		grpc.StreamInterceptor(interceptor.Stream()),
+	)
+	pb.RegisterAuthServiceServer(grpcServer, counting)
+	pb.RegisterLaptopServiceServer(grpcServer, service.NewLaptopServer(service.NewInMemoryLaptopStore(), nil, nil))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	return counting, listener.Addr().String()
+}
+
+func newTestAuthLaptopClient(t *testing.T, address string, interceptor *AuthInterceptor) *LaptopClient {
+	cc, err := grpc.Dial(
+		address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(interceptor.Unary()),
+		grpc.WithStreamInterceptor(interceptor.Stream()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { cc.Close() })
+
+	return NewLaptopClient(cc)
+}
+
+func TestAuthInterceptor(t *testing.T) {
+	t.Parallel()
+
+	server, address := startTestAuthServer(t)
+	authConn, err := grpc.Dial(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { authConn.Close() })
+
+	_, err = NewAuthInterceptor(NewAuthClient(authConn, "admin1", "wrong"), LaptopServiceAuthMethods(), time.Second)
+	require.Equal(t, codes.Unauthenticated, status.Code(errors.Unwrap(err)))
+
+	authClient := NewAuthClient(authConn, "admin1", "secret")
+	interceptor, err := NewAuthInterceptor(authClient, LaptopServiceAuthMethods(), time.Second)
+	require.NoError(t, err)
+	logins := atomic.LoadInt32(&server.logins)
+
+	laptopClient := newTestAuthLaptopClient(t, address, interceptor)
+	_, err = laptopClient.CreateLaptop(sample.NewLaptop())
+	require.NoError(t, err)
+	err = laptopClient.SearchLaptop(&pb.Filter{}, func(laptop *pb.Laptop) {})
+	require.NoError(t, err)
+
+	// 令牌还没有过期，不需要重新登录
+	require.Equal(t, logins, atomic.LoadInt32(&server.logins))
+
+	// 服务端拒绝令牌时重新登录并重试
+	interceptor.mutex.Lock()
+	interceptor.accessToken = "invalid"
+	interceptor.mutex.Unlock()
+
+	_, err = laptopClient.CreateLaptop(sample.NewLaptop())
+	require.NoError(t, err)
+	require.Equal(t, logins+1, atomic.LoadInt32(&server.logins))
+}
+
+func TestAuthInterceptorRefresh(t *testing.T) {
+	t.Parallel()
+
+	server, address := startTestAuthServer(t)
+	authConn, err := grpc.Dial(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { authConn.Close() })
+
+	// 令牌一分钟后过期，提前两分钟刷新意味着每次调用都会重新登录
+	authClient := NewAuthClient(authConn, "admin1", "secret")
+	interceptor, err := NewAuthInterceptor(authClient, LaptopServiceAuthMethods(), 2*time.Minute)
+	require.NoError(t, err)
+	logins := atomic.LoadInt32(&server.logins)
+
+	laptopClient := newTestAuthLaptopClient(t, address, interceptor)
+	for i := int32(1); i <= 3; i++ {
+		_, err = laptopClient.CreateLaptop(sample.NewLaptop())
+		require.NoError(t, err)
+		require.Equal(t, logins+i, atomic.LoadInt32(&server.logins))
+	}
+}
+
+func TestLaptopServiceAuthMethodsCoverEveryMethod(t *testing.T) {
+	t.Parallel()
+
+	methods := LaptopServiceAuthMethods()
+	path := "/" + pb.LaptopService_ServiceDesc.ServiceName + "/"
+	for _, method := range pb.LaptopService_ServiceDesc.Methods {
+		require.True(t, methods[path+method.MethodName], method.MethodName)
+	}
+	for _, stream := range pb.LaptopService_ServiceDesc.Streams {
+		require.True(t, methods[path+stream.StreamName], stream.StreamName)
+	}
+}
@@ -0,0 +1,242 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// imageChunkSize is the size of each chunk sent by UploadImage
+const imageChunkSize = 1024
+
+// LaptopClient is a client to call laptop service RPCs
+type LaptopClient struct {
+	service pb.LaptopServiceClient
+}
+
+// NewLaptopClient returns a new laptop client
+func NewLaptopClient(cc *grpc.ClientConn) *LaptopClient {
+	service := pb.NewLaptopServiceClient(cc)
+	return &LaptopClient{service}
+}
+
+// CreateLaptop calls create laptop RPC and returns the ID of the stored laptop
+func (laptopClient *LaptopClient) CreateLaptop(laptop *pb.Laptop) (string, error) {
+	req := &pb.CreateLaptopRequest{
+		Laptop: laptop,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := laptopClient.service.CreateLaptop(ctx, req)
+	if err != nil {
+		st, ok := status.FromError(err)
+		if ok && st.Code() == codes.AlreadyExists {
+			log.Print("laptop already exists")
+		}
+		return "", err
+	}
+
+	log.Printf("created laptop with id: %s", res.Id)
+	return res.Id, nil
+}
+
+// UpdateLaptop calls update laptop RPC to update the fields of laptop listed in paths,
+// expectedVersion is the version of the laptop the changes are based on
+func (laptopClient *LaptopClient) UpdateLaptop(laptop *pb.Laptop, paths []string, expectedVersion uint64) (*pb.Laptop, error) {
+	req := &pb.UpdateLaptopRequest{
+		Laptop:          laptop,
+		UpdateMask:      &fieldmaskpb.FieldMask{Paths: paths},
+		ExpectedVersion: expectedVersion,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := laptopClient.service.UpdateLaptop(ctx, req)
+	if err != nil {
+		st, ok := status.FromError(err)
+		if ok && st.Code() == codes.Aborted {
+			log.Print("laptop was modified by someone else, read it again before updating")
+		}
+		return nil, err
+	}
+
+	log.Printf("updated laptop %s to version %d", res.GetLaptop().GetId(), res.GetLaptop().GetVersion())
+	return res.GetLaptop(), nil
+}
+
+// SearchLaptop calls search laptop RPC and invokes found for every laptop received
+func (laptopClient *LaptopClient) SearchLaptop(filter *pb.Filter, found func(laptop *pb.Laptop)) error {
+	log.Print("search filter: ", filter)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req := &pb.SearchLaptopRequest{Filter: filter}
+	stream, err := laptopClient.service.SearchLaptop(ctx, req)
+	if err != nil {
+		return fmt.Errorf("cannot search laptop: %w", err)
+	}
+
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("cannot receive response: %w", err)
+		}
+
+		found(res.GetLaptop())
+	}
+}
+
+// UploadImage calls upload image RPC to upload the image file of a laptop
+func (laptopClient *LaptopClient) UploadImage(laptopID string, imagePath string) (*pb.UploadImageResponse, error) {
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open image file: %w", err)
+	}
+	defer file.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := laptopClient.service.UploadImage(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot upload image: %w", err)
+	}
+
+	req := &pb.UploadImageRequest{
+		Data: &pb.UploadImageRequest_Info{
+			Info: &pb.ImageInfo{
+				LaptopId:  laptopID,
+				ImageType: filepath.Ext(imagePath),
+			},
+		},
+	}
+
+	err = stream.Send(req)
+	if err != nil {
+		// 服务端提前关闭流时，真正的错误需要通过 CloseAndRecv 获取
+		if _, recvErr := stream.CloseAndRecv(); recvErr != nil {
+			err = recvErr
+		}
+		return nil, fmt.Errorf("cannot send image info to server: %w", err)
+	}
+
+	reader := bufio.NewReader(file)
+	buffer := make([]byte, imageChunkSize)
+
+	for {
+		n, err := reader.Read(buffer)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read chunk to buffer: %w", err)
+		}
+
+		req := &pb.UploadImageRequest{
+			Data: &pb.UploadImageRequest_ChunkData{
+				ChunkData: buffer[:n],
+			},
+		}
+
+		err = stream.Send(req)
+		if err != nil {
+			if _, recvErr := stream.CloseAndRecv(); recvErr != nil {
+				err = recvErr
+			}
+			return nil, fmt.Errorf("cannot send chunk to server: %w", err)
+		}
+	}
+
+	res, err := stream.CloseAndRecv()
+	if err != nil {
+		return nil, fmt.Errorf("cannot receive response: %w", err)
+	}
+
+	log.Printf("image uploaded with id: %s, size: %d", res.GetId(), res.GetSize())
+	return res, nil
+}
+
+// RateLaptop calls rate laptop RPC, sending one score per laptop and returning the responses in order
+func (laptopClient *LaptopClient) RateLaptop(laptopIDs []string, scores []float64) ([]*pb.RateLaptopResponse, error) {
+	if len(laptopIDs) != len(scores) {
+		return nil, fmt.Errorf("got %d laptop IDs but %d scores", len(laptopIDs), len(scores))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := laptopClient.service.RateLaptop(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot rate laptop: %w", err)
+	}
+
+	// 带缓冲，提前返回时接收方的goroutine也不会阻塞
+	waitResponse := make(chan error, 1)
+	responses := make([]*pb.RateLaptopResponse, 0, len(laptopIDs))
+
+	// 在独立的goroutine中接收服务端的响应
+	go func() {
+		for {
+			res, err := stream.Recv()
+			if err == io.EOF {
+				log.Print("no more responses")
+				waitResponse <- nil
+				return
+			}
+			if err != nil {
+				waitResponse <- fmt.Errorf("cannot receive stream response: %w", err)
+				return
+			}
+
+			log.Print("received response: ", res)
+			responses = append(responses, res)
+		}
+	}()
+
+	for i, laptopID := range laptopIDs {
+		req := &pb.RateLaptopRequest{
+			LaptopId: laptopID,
+			Score:    scores[i],
+		}
+
+		err := stream.Send(req)
+		if err != nil {
+			// 发送失败说明流已结束，等待接收方返回真正的错误
+			if recvErr := <-waitResponse; recvErr != nil {
+				return nil, recvErr
+			}
+			return nil, fmt.Errorf("cannot send stream request: %w", err)
+		}
+
+		log.Print("sent request: ", req)
+	}
+
+	err = stream.CloseSend()
+	if err != nil {
+		return nil, fmt.Errorf("cannot close send: %w", err)
+	}
+
+	err = <-waitResponse
+	if err != nil {
+		return nil, err
+	}
+	return responses, nil
+}
@@ -0,0 +1,176 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/Ruadgedy/pcbook/sample"
+	"github.com/Ruadgedy/pcbook/service"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestLaptopClient(t *testing.T) {
+	t.Parallel()
+
+	imageFolder := t.TempDir()
+	laptopServer := service.NewLaptopServer(
+		service.NewInMemoryLaptopStore(),
+		service.NewDiskImageStore(imageFolder),
+		service.NewInMemoryRatingStore(),
+	)
+
+	grpcServer := grpc.NewServer()
+	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	cc, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { cc.Close() })
+
+	laptopClient := NewLaptopClient(cc)
+
+	laptop := sample.NewLaptop()
+	id, err := laptopClient.CreateLaptop(laptop)
+	require.NoError(t, err)
+	require.Equal(t, laptop.GetId(), id)
+
+	var found []string
+	err = laptopClient.SearchLaptop(&pb.Filter{}, func(laptop *pb.Laptop) {
+		found = append(found, laptop.GetId())
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{id}, found)
+
+	updated, err := laptopClient.UpdateLaptop(&pb.Laptop{Id: id, PriceUsd: 1234}, []string{"price_usd"}, 1)
+	require.NoError(t, err)
+	require.Equal(t, 1234.0, updated.GetPriceUsd())
+	require.EqualValues(t, 2, updated.GetVersion())
+
+	_, err = laptopClient.UpdateLaptop(&pb.Laptop{Id: id, PriceUsd: 1500}, []string{"price_usd"}, 1)
+	require.Equal(t, codes.Aborted, status.Code(err))
+
+	imagePath := filepath.Join(t.TempDir(), "laptop.jpg")
+	err = ioutil.WriteFile(imagePath, make([]byte, 2500), 0644)
+	require.NoError(t, err)
+
+	image, err := laptopClient.UploadImage(id, imagePath)
+	require.NoError(t, err)
+	require.EqualValues(t, 2500, image.GetSize())
+	require.FileExists(t, filepath.Join(imageFolder, image.GetId()+".jpg"))
+
+	responses, err := laptopClient.RateLaptop([]string{id, id}, []float64{4, 8})
+	require.NoError(t, err)
+	require.Len(t, responses, 2)
+	require.EqualValues(t, 2, responses[1].GetRatedCount())
+	require.Equal(t, 6.0, responses[1].GetAverageScore())
+
+	_, err = laptopClient.RateLaptop([]string{sample.NewLaptop().GetId()}, []float64{5})
+	require.Error(t, err)
+}
+
+// fakeLaptopService returns the streams it is set to
+type fakeLaptopService struct {
+	pb.LaptopServiceClient
+	uploadImage pb.LaptopService_UploadImageClient
+	rateLaptop  pb.LaptopService_RateLaptopClient
+}
+
+func (service *fakeLaptopService) UploadImage(
+	ctx context.Context,
+	opts ...grpc.CallOption,
+) (pb.LaptopService_UploadImageClient, error) {
+	return service.uploadImage, nil
+}
+
+func (service *fakeLaptopService) RateLaptop(
+	ctx context.Context,
+	opts ...grpc.CallOption,
+) (pb.LaptopService_RateLaptopClient, error) {
+	return service.rateLaptop, nil
+}
+
+// failingUploadStream fails every Send, but CloseAndRecv returns no error
+type failingUploadStream struct {
+	grpc.ClientStream
+	err error
+}
+
+func (stream *failingUploadStream) Send(req *pb.UploadImageRequest) error {
+	return stream.err
+}
+
+func (stream *failingUploadStream) CloseAndRecv() (*pb.UploadImageResponse, error) {
+	return nil, nil
+}
+
+// failingRateStream fails CloseSend, its Recv blocks until release is closed
+type failingRateStream struct {
+	grpc.ClientStream
+	err     error
+	release chan struct{}
+}
+
+func (stream *failingRateStream) Send(req *pb.RateLaptopRequest) error {
+	return nil
+}
+
+func (stream *failingRateStream) CloseSend() error {
+	return stream.err
+}
+
+func (stream *failingRateStream) Recv() (*pb.RateLaptopResponse, error) {
+	<-stream.release
+	return nil, io.EOF
+}
+
+func TestLaptopClientUploadImageSendError(t *testing.T) {
+	t.Parallel()
+
+	imagePath := filepath.Join(t.TempDir(), "laptop.jpg")
+	err := ioutil.WriteFile(imagePath, make([]byte, 2500), 0644)
+	require.NoError(t, err)
+
+	sendErr := errors.New("connection reset")
+	laptopClient := &LaptopClient{service: &fakeLaptopService{
+		uploadImage: &failingUploadStream{err: sendErr},
+	}}
+
+	_, err = laptopClient.UploadImage(sample.NewLaptop().GetId(), imagePath)
+	require.ErrorIs(t, err, sendErr)
+}
+
+// TestLaptopClientRateLaptopCloseSendError is not parallel, so that no other RateLaptop
+// call is running while it looks for the receiving goroutine in the stack dump
+func TestLaptopClientRateLaptopCloseSendError(t *testing.T) {
+	closeErr := errors.New("connection reset")
+	stream := &failingRateStream{err: closeErr, release: make(chan struct{})}
+	laptopClient := &LaptopClient{service: &fakeLaptopService{rateLaptop: stream}}
+
+	_, err := laptopClient.RateLaptop([]string{sample.NewLaptop().GetId()}, []float64{5})
+	require.ErrorIs(t, err, closeErr)
+
+	// 接收响应的goroutine在流结束之后必须退出
+	close(stream.release)
+	require.Eventually(t, func() bool {
+		buffer := make([]byte, 1<<20)
+		stacks := string(buffer[:runtime.Stack(buffer, true)])
+		return !strings.Contains(stacks, "(*LaptopClient).RateLaptop.func1")
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/Ruadgedy/pcbook/cert"
+	"github.com/Ruadgedy/pcbook/client"
+	"github.com/Ruadgedy/pcbook/memory"
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/Ruadgedy/pcbook/sample"
+	"google.golang.org/grpc"
+)
+
+const usage = `usage: client [-address host:port] <command> [flags]
+
+commands:
+  create   create random laptops
+  search   search laptops with a filter
+  upload   upload an image for a laptop
+  rate     create random laptops and rate them
+  update   update the name or price of a laptop
+`
+
+func main() {
+	address := flag.String("address", "0.0.0.0:8080", "the server address")
+	username := flag.String("username", "admin1", "the user to log in as")
+	password := flag.String("password", "secret", "the password of the user")
+	refreshBefore := flag.Duration("refresh-before", time.Minute, "how long before it expires the access token is refreshed")
+	tlsMode := flag.String("tls", string(cert.Plaintext), "the transport security: plaintext, tls or mtls")
+	caFile := flag.String("tls-ca", "tmp/cert/ca-cert.pem", "the CA certificate that signs the server certificate")
+	certFile := flag.String("tls-cert", "tmp/cert/client-cert.pem", "the client certificate, for mtls")
+	keyFile := flag.String("tls-key", "tmp/cert/client-key.pem", "the private key of the client certificate, for mtls")
+	serverName := flag.String("tls-server-name", "", "the host name to verify the server certificate against, defaults to the host of -address")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	mode, err := cert.ParseMode(*tlsMode)
+	if err != nil {
+		log.Fatal(err)
+	}
+	transportCredentials, err := cert.ClientConfig{
+		Mode:       mode,
+		CAFile:     *caFile,
+		CertFile:   *certFile,
+		KeyFile:    *keyFile,
+		ServerName: *serverName,
+	}.Credentials()
+	if err != nil {
+		log.Fatal("cannot load TLS credentials: ", err)
+	}
+
+	log.Printf("dial server %s with %s", *address, mode)
+	authConn, err := grpc.Dial(*address, grpc.WithTransportCredentials(transportCredentials))
+	if err != nil {
+		log.Fatal("cannot dial server: ", err)
+	}
+	defer authConn.Close()
+
+	authClient := client.NewAuthClient(authConn, *username, *password)
+	interceptor, err := client.NewAuthInterceptor(authClient, client.LaptopServiceAuthMethods(), *refreshBefore)
+	if err != nil {
+		log.Fatal("cannot create auth interceptor: ", err)
+	}
+
+	cc, err := grpc.Dial(
+		*address,
+		grpc.WithTransportCredentials(transportCredentials),
+		grpc.WithUnaryInterceptor(interceptor.Unary()),
+		grpc.WithStreamInterceptor(interceptor.Stream()),
+	)
+	if err != nil {
+		log.Fatal("cannot dial server: ", err)
+	}
+	defer cc.Close()
+
+	laptopClient := client.NewLaptopClient(cc)
+
+	command, args := flag.Arg(0), flag.Args()[1:]
+	switch command {
+	case "create":
+		err = runCreate(laptopClient, args)
+	case "search":
+		err = runSearch(laptopClient, args)
+	case "upload":
+		err = runUpload(laptopClient, args)
+	case "rate":
+		err = runRate(laptopClient, args)
+	case "update":
+		err = runUpdate(laptopClient, args)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func runCreate(laptopClient *client.LaptopClient, args []string) error {
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	count := flags.Int("n", 10, "the number of random laptops to create")
+	flags.Parse(args)
+
+	for i := 0; i < *count; i++ {
+		if _, err := laptopClient.CreateLaptop(sample.NewLaptop()); err != nil {
+			return fmt.Errorf("cannot create laptop: %w", err)
+		}
+	}
+	return nil
+}
+
+func runSearch(laptopClient *client.LaptopClient, args []string) error {
+	flags := flag.NewFlagSet("search", flag.ExitOnError)
+	maxPrice := flags.Float64("max-price", 3000, "the maximum price in USD, 0 means no limit")
+	minCores := flags.Uint("min-cores", 4, "the minimum number of CPU cores")
+	minGhz := flags.Float64("min-ghz", 2.5, "the minimum CPU frequency in GHz")
+	minRAM := flags.String("min-ram", "8 GB", "the minimum RAM, e.g. \"8 GB\" or \"512MiB\"")
+	flags.Parse(args)
+
+	ram, err := memory.Parse(*minRAM)
+	if err != nil {
+		return fmt.Errorf("invalid -min-ram: %w", err)
+	}
+
+	filter := &pb.Filter{
+		MaxPriceUsd: *maxPrice,
+		MinCpuCores: uint32(*minCores),
+		MinCpuGhz:   *minGhz,
+		MinRam:      ram,
+	}
+
+	return laptopClient.SearchLaptop(filter, func(laptop *pb.Laptop) {
+		log.Print("- found: ", laptop.GetId())
+		log.Print("  + brand: ", laptop.GetBrand())
+		log.Print("  + name: ", laptop.GetName())
+		log.Print("  + cpu cores: ", laptop.GetCpu().GetNumberCores())
+		log.Print("  + cpu min ghz: ", laptop.GetCpu().GetMinGhz())
+		log.Print("  + ram: ", memory.Format(laptop.GetRam()))
+		log.Print("  + price: ", laptop.GetPriceUsd(), "usd")
+	})
+}
+
+func runUpload(laptopClient *client.LaptopClient, args []string) error {
+	flags := flag.NewFlagSet("upload", flag.ExitOnError)
+	laptopID := flags.String("laptop-id", "", "the laptop to attach the image to, a new random laptop is created if empty")
+	imagePath := flags.String("image", "", "the path of the image file to upload")
+	flags.Parse(args)
+
+	if *imagePath == "" {
+		return fmt.Errorf("the -image flag is required")
+	}
+
+	if *laptopID == "" {
+		id, err := laptopClient.CreateLaptop(sample.NewLaptop())
+		if err != nil {
+			return fmt.Errorf("cannot create laptop: %w", err)
+		}
+		*laptopID = id
+	}
+
+	_, err := laptopClient.UploadImage(*laptopID, *imagePath)
+	return err
+}
+
+func runRate(laptopClient *client.LaptopClient, args []string) error {
+	flags := flag.NewFlagSet("rate", flag.ExitOnError)
+	count := flags.Int("n", 3, "the number of random laptops to create and rate")
+	rounds := flags.Int("rounds", 3, "the number of times each laptop is rated")
+	flags.Parse(args)
+
+	laptopIDs := make([]string, *count)
+	for i := range laptopIDs {
+		id, err := laptopClient.CreateLaptop(sample.NewLaptop())
+		if err != nil {
+			return fmt.Errorf("cannot create laptop: %w", err)
+		}
+		laptopIDs[i] = id
+	}
+
+	scores := make([]float64, *count)
+	for round := 0; round < *rounds; round++ {
+		for i := range scores {
+			scores[i] = sample.RandomLaptopScore()
+		}
+
+		responses, err := laptopClient.RateLaptop(laptopIDs, scores)
+		if err != nil {
+			return fmt.Errorf("cannot rate laptop: %w", err)
+		}
+
+		for _, res := range responses {
+			log.Printf("laptop %s: rated %d times, average score %.2f",
+				res.GetLaptopId(), res.GetRatedCount(), res.GetAverageScore())
+		}
+	}
+	return nil
+}
+
+func runUpdate(laptopClient *client.LaptopClient, args []string) error {
+	flags := flag.NewFlagSet("update", flag.ExitOnError)
+	laptopID := flags.String("laptop-id", "", "the laptop to update")
+	version := flags.Uint64("version", 0, "the version of the laptop the update is based on")
+	name := flags.String("name", "", "the new name of the laptop")
+	price := flags.Float64("price", 0, "the new price in USD")
+	flags.Parse(args)
+
+	if *laptopID == "" {
+		return fmt.Errorf("the -laptop-id flag is required")
+	}
+
+	// 只更新命令行中指定的字段
+	laptop := &pb.Laptop{Id: *laptopID, Name: *name, PriceUsd: *price}
+	var paths []string
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "name":
+			paths = append(paths, "name")
+		case "price":
+			paths = append(paths, "price_usd")
+		}
+	})
+	if len(paths) == 0 {
+		return fmt.Errorf("nothing to update, set -name or -price")
+	}
+
+	_, err := laptopClient.UpdateLaptop(laptop, paths, *version)
+	return err
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Ruadgedy/pcbook/cert"
+)
+
+func main() {
+	outDir := flag.String("out", "tmp/cert", "the folder to write the certificates and keys to")
+	hosts := flag.String("hosts", "localhost,127.0.0.1,0.0.0.0", "the comma-separated DNS names and IP addresses of the server certificate")
+	clientName := flag.String("client-name", "pcbook-client", "the common name of the client certificate")
+	validity := flag.Duration("validity", 365*24*time.Hour, "the validity of the generated certificates")
+	flag.Parse()
+
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		log.Fatal("cannot create output folder: ", err)
+	}
+
+	ca, err := cert.NewAuthority("PC Book Development CA", *validity)
+	if err != nil {
+		log.Fatal(err)
+	}
+	serverPair, err := ca.IssueServer(strings.Split(*hosts, ","))
+	if err != nil {
+		log.Fatal(err)
+	}
+	clientPair, err := ca.IssueClient(*clientName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	pairs := []struct {
+		name string
+		pair *cert.KeyPair
+	}{
+		{"ca", &ca.KeyPair},
+		{"server", serverPair},
+		{"client", clientPair},
+	}
+	for _, p := range pairs {
+		certFile := filepath.Join(*outDir, p.name+"-cert.pem")
+		keyFile := filepath.Join(*outDir, p.name+"-key.pem")
+		if err := p.pair.WriteFiles(certFile, keyFile); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("wrote %s and %s", certFile, keyFile)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Ruadgedy/pcbook/cert"
+	"github.com/Ruadgedy/pcbook/gateway"
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/Ruadgedy/pcbook/service"
+	_ "github.com/mattn/go-sqlite3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func main() {
+	port := flag.Int("port", 8080, "the server port")
+	httpPort := flag.Int("http-port", 0, "the port of the REST gateway, served with the same transport security as gRPC, disabled if it is 0")
+	imageFolder := flag.String("image-folder", "tmp", "the folder to store uploaded laptop images")
+	maxImageSize := flag.Int("max-image-size", service.DefaultMaxImageSize, "the maximum size in bytes of an uploaded image")
+	laptopLog := flag.String("laptop-store", "", "the file to persist laptops to, laptops are only kept in memory if it is empty")
+	laptopDB := flag.String("laptop-db", "", "the SQLite database to store laptops in, instead of -laptop-store")
+	usersFile := flag.String("users", "", "the YAML file of the users who can log in, required unless -dev-users is set")
+	devUsers := flag.Bool("dev-users", false, "seed the development users admin1 and user1 with password \"secret\", only for local development")
+	secretKey := flag.String("secret-key", os.Getenv("PCBOOK_SECRET_KEY"), "the key to sign access tokens, defaults to $PCBOOK_SECRET_KEY")
+	tokenDuration := flag.Duration("token-duration", 15*time.Minute, "the lifetime of access tokens")
+	healthInterval := flag.Duration("health-interval", 10*time.Second, "how often the stores are checked to report the health of the services")
+	tlsMode := flag.String("tls", string(cert.Plaintext), "the transport security: plaintext, tls or mtls")
+	certFile := flag.String("tls-cert", "tmp/cert/server-cert.pem", "the server certificate, for tls and mtls")
+	keyFile := flag.String("tls-key", "tmp/cert/server-key.pem", "the private key of the server certificate, for tls and mtls")
+	clientCAFile := flag.String("tls-client-ca", "tmp/cert/ca-cert.pem", "the CA certificate that signs the client certificates, for mtls")
+	flag.Parse()
+
+	mode, err := cert.ParseMode(*tlsMode)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tlsConfig := cert.ServerConfig{
+		Mode:         mode,
+		CertFile:     *certFile,
+		KeyFile:      *keyFile,
+		ClientCAFile: *clientCAFile,
+	}
+	transportCredentials, err := tlsConfig.Credentials()
+	if err != nil {
+		log.Fatal("cannot load TLS credentials: ", err)
+	}
+
+	userStore := service.NewInMemoryUserStore()
+	if err := seedUsers(userStore, *usersFile, *devUsers); err != nil {
+		log.Fatal("cannot seed users: ", err)
+	}
+
+	if *secretKey == "" {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			log.Fatal("cannot generate secret key: ", err)
+		}
+		*secretKey = hex.EncodeToString(key)
+		log.Print("no -secret-key given, access tokens are only valid until the server restarts")
+	}
+	jwtManager := service.NewJWTManager(*secretKey, *tokenDuration)
+	authServer, err := service.NewAuthServer(userStore, jwtManager)
+	if err != nil {
+		log.Fatal("cannot create auth server: ", err)
+	}
+
+	if err := os.MkdirAll(*imageFolder, 0755); err != nil {
+		log.Fatal("cannot create image folder: ", err)
+	}
+
+	var laptopStore service.LaptopStore = service.NewInMemoryLaptopStore()
+	switch {
+	case *laptopLog != "" && *laptopDB != "":
+		log.Fatal("cannot use both -laptop-store and -laptop-db")
+	case *laptopDB != "":
+		db, err := sql.Open("sqlite3", service.SQLiteDSN(*laptopDB))
+		if err != nil {
+			log.Fatal("cannot open laptop database: ", err)
+		}
+		defer db.Close()
+
+		laptopStore, err = service.NewSQLLaptopStore(db)
+		if err != nil {
+			log.Fatal("cannot open laptop store: ", err)
+		}
+	case *laptopLog != "":
+		diskStore, err := service.NewDiskLaptopStore(*laptopLog)
+		if err != nil {
+			log.Fatal("cannot open laptop store: ", err)
+		}
+		defer diskStore.Close()
+		laptopStore = diskStore
+	}
+
+	imageStore := service.NewDiskImageStore(*imageFolder)
+	ratingStore := service.NewInMemoryRatingStore()
+
+	laptopServer := service.NewLaptopServer(
+		laptopStore,
+		imageStore,
+		ratingStore,
+		service.WithMaxImageSize(*maxImageSize),
+	)
+
+	// 服务的健康状态取决于其使用的存储是否可用
+	healthServer := health.NewServer()
+	healthReporter := service.NewHealthReporter(healthServer)
+	healthReporter.Register(pb.AuthService_ServiceDesc.ServiceName, authServer)
+	healthReporter.Register(pb.LaptopService_ServiceDesc.ServiceName, laptopServer)
+	healthReporter.Check(context.Background())
+	go healthReporter.Run(context.Background(), *healthInterval)
+
+	interceptor := service.NewAuthInterceptor(jwtManager, service.LaptopServiceRoles(), service.PublicMethods())
+	newGRPCServer := func(opts ...grpc.ServerOption) *grpc.Server {
+		opts = append(opts,
+			grpc.UnaryInterceptor(interceptor.Unary()),
+			grpc.StreamInterceptor(interceptor.Stream()),
+		)
+		grpcServer := grpc.NewServer(opts...)
+		pb.RegisterAuthServiceServer(grpcServer, authServer)
+		pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+		healthpb.RegisterHealthServer(grpcServer, healthServer)
+		reflection.Register(grpcServer)
+		return grpcServer
+	}
+	grpcServer := newGRPCServer(grpc.Creds(transportCredentials))
+
+	if *httpPort != 0 {
+		httpTLSConfig, err := tlsConfig.TLSConfig()
+		if err != nil {
+			log.Fatal("cannot load TLS config: ", err)
+		}
+		go serveGateway(newGRPCServer(), *httpPort, httpTLSConfig)
+	}
+
+	address := fmt.Sprintf("0.0.0.0:%d", *port)
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Fatal("cannot start server: ", err)
+	}
+
+	log.Printf("start server on %s with %s", address, mode)
+	err = grpcServer.Serve(listener)
+	if err != nil {
+		log.Fatal("cannot start server: ", err)
+	}
+}
+
+// serveGateway serves the REST gateway on port, with TLS if tlsConfig is not nil.
+// The gateway calls grpcServer through an in-process connection that doesn't go
+// through the network, so it doesn't need client credentials even with mutual TLS.
+func serveGateway(grpcServer *grpc.Server, port int, tlsConfig *tls.Config) {
+	bufListener := bufconn.Listen(1 << 20)
+	go grpcServer.Serve(bufListener)
+
+	cc, err := grpc.Dial(
+		"bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return bufListener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		log.Fatal("cannot dial in-process server: ", err)
+	}
+
+	address := fmt.Sprintf("0.0.0.0:%d", port)
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Fatal("cannot start REST gateway: ", err)
+	}
+
+	httpServer := &http.Server{
+		Handler:   gateway.NewLaptopGateway(cc),
+		TLSConfig: tlsConfig,
+	}
+
+	log.Printf("start REST gateway on %s", address)
+	if tlsConfig != nil {
+		err = httpServer.ServeTLS(listener, "", "")
+	} else {
+		err = httpServer.Serve(listener)
+	}
+	log.Fatal("cannot start REST gateway: ", err)
+}
+
+// seedUsers saves the users of the config file to the store, or the development users if devUsers is set
+func seedUsers(userStore service.UserStore, filename string, devUsers bool) error {
+	switch {
+	case filename != "" && devUsers:
+		return errors.New("cannot use both -users and -dev-users")
+	case filename == "" && !devUsers:
+		return errors.New("no users to log in, give a -users file, or -dev-users for local development")
+	case devUsers:
+		log.Print("seeding development users admin1 and user1 with password \"secret\", do not use -dev-users in production")
+		return service.SeedUsers(userStore, []service.UserConfig{
+			{Username: "admin1", Password: "secret", Role: service.RoleAdmin},
+			{Username: "user1", Password: "secret", Role: service.RoleUser},
+		})
+	}
+
+	configs, err := service.LoadUserConfigs(filename)
+	if err != nil {
+		return err
+	}
+	return service.SeedUsers(userStore, configs)
+}
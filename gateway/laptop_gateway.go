@@ -0,0 +1,283 @@
+// Package gateway serves the laptop gRPC API as REST endpoints with JSON bodies,
+// for clients that cannot speak gRPC.
+package gateway
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/Ruadgedy/pcbook/serializer"
+	_ "google.golang.org/genproto/googleapis/rpc/errdetails" // 注册错误详情类型，才能把它们转换为JSON
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// MaxRequestBodySize is the maximum size of a request body: 1 megabyte
+const MaxRequestBodySize = 1 << 20
+
+// ndjsonContentType is the content type of search results written as newline-delimited JSON
+const ndjsonContentType = "application/x-ndjson"
+
+// jsonOptions writes compact JSON with the same field names as serializer.ProtobufToJSON
+var jsonOptions = func() serializer.JSONOptions {
+	options := serializer.DefaultJSONOptions()
+	options.Indent = ""
+	return options
+}()
+
+// LaptopGateway is an HTTP handler that forwards REST requests to the laptop and auth services:
+//
+//	POST /v1/auth/login            body LoginRequest      returns LoginResponse
+//	POST /v1/laptops               body Laptop            returns CreateLaptopResponse
+//	GET  /v1/laptops/{id}                                 returns GetLaptopResponse
+//	GET  /v1/laptops?{filter}                             returns SearchLaptopResponse array
+//	POST /v1/laptops/{id}/ratings  body RateLaptopRequest returns RateLaptopResponse
+//
+// The search filter is given as query parameters such as "min_ram.value=8&min_ram.unit=GIGABYTE",
+// its results are newline-delimited JSON if the request accepts application/x-ndjson.
+// The Authorization header is forwarded to the services, and errors are written as
+// google.rpc.Status JSON with the HTTP status matching their gRPC code.
+type LaptopGateway struct {
+	laptopClient pb.LaptopServiceClient
+	authClient   pb.AuthServiceClient
+	mux          *http.ServeMux
+}
+
+// NewLaptopGateway returns a new LaptopGateway that calls the services through cc
+func NewLaptopGateway(cc *grpc.ClientConn) *LaptopGateway {
+	gateway := &LaptopGateway{
+		laptopClient: pb.NewLaptopServiceClient(cc),
+		authClient:   pb.NewAuthServiceClient(cc),
+		mux:          http.NewServeMux(),
+	}
+
+	gateway.mux.HandleFunc("/v1/auth/login", gateway.handleLogin)
+	gateway.mux.HandleFunc("/v1/laptops", gateway.handleLaptops)
+	gateway.mux.HandleFunc("/v1/laptops/", gateway.handleLaptop)
+	gateway.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, status.Errorf(codes.NotFound, "path %s is not found", r.URL.Path))
+	})
+	return gateway
+}
+
+// ServeHTTP implements http.Handler
+func (gateway *LaptopGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	gateway.mux.ServeHTTP(w, r)
+}
+
+func (gateway *LaptopGateway) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	req := &pb.LoginRequest{}
+	if !readBody(w, r, req) {
+		return
+	}
+
+	res, err := gateway.authClient.Login(r.Context(), req)
+	writeResponse(w, res, err)
+}
+
+// handleLaptops serves the laptop collection: POST creates a laptop, GET searches laptops
+func (gateway *LaptopGateway) handleLaptops(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		laptop := &pb.Laptop{}
+		if !readBody(w, r, laptop) {
+			return
+		}
+
+		res, err := gateway.laptopClient.CreateLaptop(outgoingContext(r), &pb.CreateLaptopRequest{Laptop: laptop})
+		writeResponse(w, res, err)
+		return
+	}
+
+	filter := &pb.Filter{}
+	if err := setQueryParams(filter, r.URL.Query()); err != nil {
+		writeError(w, status.Error(codes.InvalidArgument, err.Error()))
+		return
+	}
+	gateway.searchLaptop(w, r, filter)
+}
+
+// handleLaptop serves a single laptop: GET /v1/laptops/{id} and POST /v1/laptops/{id}/ratings
+func (gateway *LaptopGateway) handleLaptop(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/laptops/"), "/")
+
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		res, err := gateway.laptopClient.GetLaptop(outgoingContext(r), &pb.GetLaptopRequest{Id: parts[0]})
+		writeResponse(w, res, err)
+	case len(parts) == 2 && parts[0] != "" && parts[1] == "ratings":
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+		req := &pb.RateLaptopRequest{}
+		if !readBody(w, r, req) {
+			return
+		}
+		// 路径中的ID优先于请求体中的ID
+		req.LaptopId = parts[0]
+		res, err := gateway.rateLaptop(outgoingContext(r), req)
+		writeResponse(w, res, err)
+	default:
+		writeError(w, status.Errorf(codes.NotFound, "path %s is not found", r.URL.Path))
+	}
+}
+
+// searchLaptop writes the search results as they are received. Once the first result is
+// written the status can no longer change, so a later error aborts the response instead.
+func (gateway *LaptopGateway) searchLaptop(w http.ResponseWriter, r *http.Request, filter *pb.Filter) {
+	stream, err := gateway.laptopClient.SearchLaptop(outgoingContext(r), &pb.SearchLaptopRequest{Filter: filter})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	res, err := stream.Recv()
+	if err != nil && err != io.EOF {
+		writeError(w, err)
+		return
+	}
+
+	var writer serializer.StreamWriter
+	if strings.Contains(r.Header.Get("Accept"), ndjsonContentType) {
+		w.Header().Set("Content-Type", ndjsonContentType)
+		writer = serializer.NewNDJSONWriter(w)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		writer = serializer.NewJSONArrayWriter(w)
+	}
+	w.WriteHeader(http.StatusOK)
+
+	for ; err != io.EOF; res, err = stream.Recv() {
+		if err == nil {
+			err = writer.Write(res)
+		}
+		if err != nil {
+			log.Printf("abort search-laptop response: %v", err)
+			panic(http.ErrAbortHandler)
+		}
+	}
+
+	if err = writer.Close(); err != nil {
+		log.Printf("cannot write search-laptop response: %v", err)
+	}
+}
+
+// rateLaptop sends a single rating over the RateLaptop stream and returns its response
+func (gateway *LaptopGateway) rateLaptop(ctx context.Context, req *pb.RateLaptopRequest) (*pb.RateLaptopResponse, error) {
+	stream, err := gateway.laptopClient.RateLaptop(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = stream.Send(req); err != nil && err != io.EOF {
+		return nil, err
+	}
+	// Send 返回 io.EOF 时，真正的错误由 Recv 返回
+	if err = stream.CloseSend(); err != nil {
+		return nil, err
+	}
+
+	res, err := stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+
+	// 等待服务端结束stream
+	for err == nil {
+		_, err = stream.Recv()
+	}
+	if err != io.EOF {
+		return nil, err
+	}
+	return res, nil
+}
+
+// outgoingContext returns the request context carrying the Authorization header as gRPC metadata
+func outgoingContext(r *http.Request) context.Context {
+	ctx := r.Context()
+	if authorization := r.Header.Get("Authorization"); authorization != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", authorization)
+	}
+	return ctx
+}
+
+// allowMethod writes a 405 response and returns false if the request method is not one of methods
+func allowMethod(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeStatus(w, http.StatusMethodNotAllowed,
+		status.Newf(codes.Unimplemented, "method %s is not allowed on %s", r.Method, r.URL.Path))
+	return false
+}
+
+// readBody parses the JSON request body into message, it writes a 400 response and returns false on error
+func readBody(w http.ResponseWriter, r *http.Request, message proto.Message) bool {
+	data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MaxRequestBodySize))
+	if err != nil {
+		writeError(w, status.Errorf(codes.InvalidArgument, "cannot read request body: %v", err))
+		return false
+	}
+
+	if err = serializer.JSONToProtobufWithOptions(data, message, jsonOptions); err != nil {
+		writeError(w, status.Errorf(codes.InvalidArgument, "cannot parse request body: %v", err))
+		return false
+	}
+	return true
+}
+
+// writeResponse writes the response of a unary call, or its error
+func writeResponse(w http.ResponseWriter, res proto.Message, err error) {
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeMessage(w, http.StatusOK, res)
+}
+
+// writeError writes err as a google.rpc.Status with the HTTP status of its gRPC code
+func writeError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	writeStatus(w, HTTPStatusFromCode(st.Code()), st)
+}
+
+func writeStatus(w http.ResponseWriter, httpStatus int, st *status.Status) {
+	writeMessage(w, httpStatus, st.Proto())
+}
+
+func writeMessage(w http.ResponseWriter, httpStatus int, message proto.Message) {
+	data, err := serializer.ProtobufToJSONWithOptions(message, jsonOptions)
+	if err != nil {
+		log.Printf("cannot marshal response: %v", err)
+		http.Error(w, "cannot marshal response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	if _, err = io.WriteString(w, data+"\n"); err != nil {
+		log.Printf("cannot write response: %v", err)
+	}
+}
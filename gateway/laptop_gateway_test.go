@@ -0,0 +1,354 @@
+package gateway
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/Ruadgedy/pcbook/sample"
+	"github.com/Ruadgedy/pcbook/serializer"
+	"github.com/Ruadgedy/pcbook/service"
+	"github.com/stretchr/testify/require"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+)
+
+// startTestGateway starts a laptop server with authentication and a gateway in front of it
+func startTestGateway(t *testing.T) string {
+	userStore := service.NewInMemoryUserStore()
+	err := service.SeedUsers(userStore, []service.UserConfig{
+		{Username: "admin1", Password: "secret", Role: service.RoleAdmin},
+		{Username: "user1", Password: "secret", Role: service.RoleUser},
+	})
+	require.NoError(t, err)
+
+	jwtManager := service.NewJWTManager("secret key", time.Minute)
+	authServer, err := service.NewAuthServer(userStore, jwtManager)
+	require.NoError(t, err)
+
+	laptopServer := service.NewLaptopServer(
+		service.NewInMemoryLaptopStore(),
+		service.NewDiskImageStore(t.TempDir()),
+		service.NewInMemoryRatingStore(),
+	)
+
+	interceptor := service.NewAuthInterceptor(jwtManager, service.LaptopServiceRoles(), service.PublicMethods())
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(interceptor.Unary()),
+		grpc.StreamInterceptor(interceptor.Stream()),
+	)
+	pb.RegisterAuthServiceServer(grpcServer, authServer)
+	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	cc, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { cc.Close() })
+
+	httpServer := httptest.NewServer(NewLaptopGateway(cc))
+	t.Cleanup(httpServer.Close)
+
+	return httpServer.URL
+}
+
+// doRequest sends a request with a JSON body and an optional access token,
+// it returns the response status and body
+func doRequest(t *testing.T, method string, url string, token string, body string) (int, []byte) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	data, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	return res.StatusCode, data
+}
+
+func login(t *testing.T, baseURL string, username string) string {
+	code, data := doRequest(t, http.MethodPost, baseURL+"/v1/auth/login", "",
+		`{"username": "`+username+`", "password": "secret"}`)
+	require.Equal(t, http.StatusOK, code, string(data))
+
+	res := &pb.LoginResponse{}
+	require.NoError(t, serializer.JSONToProtobuf(data, res))
+	require.NotEmpty(t, res.AccessToken)
+	return res.AccessToken
+}
+
+func createLaptop(t *testing.T, baseURL string, token string, laptop *pb.Laptop) {
+	code, data := doRequest(t, http.MethodPost, baseURL+"/v1/laptops", token, mustJSON(t, laptop))
+	require.Equal(t, http.StatusOK, code, string(data))
+
+	res := &pb.CreateLaptopResponse{}
+	require.NoError(t, serializer.JSONToProtobuf(data, res))
+	require.Equal(t, laptop.Id, res.Id)
+}
+
+func TestLaptopGateway(t *testing.T) {
+	t.Parallel()
+
+	baseURL := startTestGateway(t)
+	adminToken := login(t, baseURL, "admin1")
+	userToken := login(t, baseURL, "user1")
+
+	laptop := sample.NewLaptop()
+	laptop.PriceUsd = 2000
+	laptop.Ram = &pb.Memory{Value: 16, Unit: pb.Memory_GIGABYTE}
+	createLaptop(t, baseURL, adminToken, laptop)
+
+	expensive := sample.NewLaptop()
+	expensive.PriceUsd = 3200
+	createLaptop(t, baseURL, adminToken, expensive)
+
+	// 获取笔记本，版本号和更新时间由服务端设置
+	code, data := doRequest(t, http.MethodGet, baseURL+"/v1/laptops/"+laptop.Id, userToken, "")
+	require.Equal(t, http.StatusOK, code, string(data))
+	require.Contains(t, string(data), `"price_usd"`)
+	getRes := &pb.GetLaptopResponse{}
+	require.NoError(t, serializer.JSONToProtobuf(data, getRes))
+	require.Equal(t, uint64(1), getRes.Laptop.Version)
+	laptop.Version = 1
+	laptop.UpdatedAt = getRes.Laptop.UpdatedAt
+	require.True(t, proto.Equal(laptop, getRes.Laptop))
+
+	// 搜索结果默认为JSON数组
+	query := url.Values{}
+	query.Set("max_price_usd", "3000")
+	query.Set("min_ram.value", "8")
+	query.Set("min_ram.unit", "GIGABYTE")
+	code, data = doRequest(t, http.MethodGet, baseURL+"/v1/laptops?"+query.Encode(), userToken, "")
+	require.Equal(t, http.StatusOK, code, string(data))
+
+	reader := serializer.NewJSONArrayReader(strings.NewReader(string(data)))
+	searchRes := &pb.SearchLaptopResponse{}
+	require.NoError(t, reader.Read(searchRes))
+	require.Equal(t, laptop.Id, searchRes.Laptop.Id)
+	require.Error(t, reader.Read(searchRes))
+
+	// 请求 application/x-ndjson 时按行返回
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/v1/laptops", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+userToken)
+	req.Header.Set("Accept", "application/x-ndjson")
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "application/x-ndjson", res.Header.Get("Content-Type"))
+
+	ids := map[string]bool{}
+	ndjsonReader := serializer.NewNDJSONReader(res.Body)
+	for ndjsonReader.Read(searchRes) == nil {
+		ids[searchRes.Laptop.Id] = true
+	}
+	require.Equal(t, map[string]bool{laptop.Id: true, expensive.Id: true}, ids)
+
+	// 评分
+	for i, score := range []float64{8, 10} {
+		code, data = doRequest(t, http.MethodPost, baseURL+"/v1/laptops/"+laptop.Id+"/ratings", userToken,
+			fmt.Sprintf(`{"score": %v}`, score))
+		require.Equal(t, http.StatusOK, code, string(data))
+		require.Contains(t, string(data), `"rated_count"`)
+
+		rateRes := &pb.RateLaptopResponse{}
+		require.NoError(t, serializer.JSONToProtobuf(data, rateRes))
+		require.Equal(t, laptop.Id, rateRes.LaptopId)
+		require.Equal(t, uint32(i+1), rateRes.RatedCount)
+	}
+}
+
+func TestLaptopGatewayErrors(t *testing.T) {
+	t.Parallel()
+
+	baseURL := startTestGateway(t)
+	adminToken := login(t, baseURL, "admin1")
+	userToken := login(t, baseURL, "user1")
+
+	laptop := sample.NewLaptop()
+	createLaptop(t, baseURL, adminToken, laptop)
+	laptopJSON := mustJSON(t, sample.NewLaptop())
+
+	testCases := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       string
+		httpStatus int
+		code       codes.Code
+	}{
+		{
+			name:       "login_wrong_password",
+			method:     http.MethodPost,
+			path:       "/v1/auth/login",
+			body:       `{"username": "admin1", "password": "wrong"}`,
+			httpStatus: http.StatusUnauthorized,
+			code:       codes.Unauthenticated,
+		},
+		{
+			name:       "create_without_token",
+			method:     http.MethodPost,
+			path:       "/v1/laptops",
+			body:       laptopJSON,
+			httpStatus: http.StatusUnauthorized,
+			code:       codes.Unauthenticated,
+		},
+		{
+			name:       "create_as_user",
+			method:     http.MethodPost,
+			path:       "/v1/laptops",
+			token:      userToken,
+			body:       laptopJSON,
+			httpStatus: http.StatusForbidden,
+			code:       codes.PermissionDenied,
+		},
+		{
+			name:       "create_invalid_json",
+			method:     http.MethodPost,
+			path:       "/v1/laptops",
+			token:      adminToken,
+			body:       `{"brand": `,
+			httpStatus: http.StatusBadRequest,
+			code:       codes.InvalidArgument,
+		},
+		{
+			name:       "create_invalid_laptop",
+			method:     http.MethodPost,
+			path:       "/v1/laptops",
+			token:      adminToken,
+			body:       `{"brand": "Apple"}`,
+			httpStatus: http.StatusBadRequest,
+			code:       codes.InvalidArgument,
+		},
+		{
+			name:       "create_duplicate",
+			method:     http.MethodPost,
+			path:       "/v1/laptops",
+			token:      adminToken,
+			body:       mustJSON(t, laptop),
+			httpStatus: http.StatusConflict,
+			code:       codes.AlreadyExists,
+		},
+		{
+			name:       "get_invalid_id",
+			method:     http.MethodGet,
+			path:       "/v1/laptops/invalid-uuid",
+			token:      userToken,
+			httpStatus: http.StatusBadRequest,
+			code:       codes.InvalidArgument,
+		},
+		{
+			name:       "get_not_found",
+			method:     http.MethodGet,
+			path:       "/v1/laptops/" + sample.NewLaptop().Id,
+			token:      userToken,
+			httpStatus: http.StatusNotFound,
+			code:       codes.NotFound,
+		},
+		{
+			name:       "search_unknown_param",
+			method:     http.MethodGet,
+			path:       "/v1/laptops?max_weight=2",
+			token:      userToken,
+			httpStatus: http.StatusBadRequest,
+			code:       codes.InvalidArgument,
+		},
+		{
+			name:       "search_without_token",
+			method:     http.MethodGet,
+			path:       "/v1/laptops",
+			httpStatus: http.StatusUnauthorized,
+			code:       codes.Unauthenticated,
+		},
+		{
+			name:       "rate_invalid_score",
+			method:     http.MethodPost,
+			path:       "/v1/laptops/" + laptop.Id + "/ratings",
+			token:      userToken,
+			body:       `{"score": 11}`,
+			httpStatus: http.StatusBadRequest,
+			code:       codes.InvalidArgument,
+		},
+		{
+			name:       "rate_not_found",
+			method:     http.MethodPost,
+			path:       "/v1/laptops/" + sample.NewLaptop().Id + "/ratings",
+			token:      userToken,
+			body:       `{"score": 5}`,
+			httpStatus: http.StatusNotFound,
+			code:       codes.NotFound,
+		},
+		{
+			name:       "method_not_allowed",
+			method:     http.MethodDelete,
+			path:       "/v1/laptops/" + laptop.Id,
+			token:      adminToken,
+			httpStatus: http.StatusMethodNotAllowed,
+			code:       codes.Unimplemented,
+		},
+		{
+			name:       "unknown_path",
+			method:     http.MethodGet,
+			path:       "/v1/laptops/" + laptop.Id + "/images",
+			token:      adminToken,
+			httpStatus: http.StatusNotFound,
+			code:       codes.NotFound,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			httpStatus, data := doRequest(t, tc.method, baseURL+tc.path, tc.token, tc.body)
+			require.Equal(t, tc.httpStatus, httpStatus, string(data))
+
+			st := &spb.Status{}
+			require.NoError(t, serializer.JSONToProtobuf(data, st))
+			require.Equal(t, tc.code, codes.Code(st.Code))
+			require.NotEmpty(t, st.Message)
+		})
+	}
+}
+
+func mustJSON(t *testing.T, message proto.Message) string {
+	data, err := serializer.ProtobufToJSON(message)
+	require.NoError(t, err)
+	return data
+}
+
+func TestHTTPStatusFromCode(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, http.StatusOK, HTTPStatusFromCode(codes.OK))
+	require.Equal(t, 499, HTTPStatusFromCode(codes.Canceled))
+	require.Equal(t, http.StatusBadRequest, HTTPStatusFromCode(codes.FailedPrecondition))
+	require.Equal(t, http.StatusGatewayTimeout, HTTPStatusFromCode(codes.DeadlineExceeded))
+	require.Equal(t, http.StatusConflict, HTTPStatusFromCode(codes.Aborted))
+	require.Equal(t, http.StatusTooManyRequests, HTTPStatusFromCode(codes.ResourceExhausted))
+	require.Equal(t, http.StatusNotImplemented, HTTPStatusFromCode(codes.Unimplemented))
+	require.Equal(t, http.StatusServiceUnavailable, HTTPStatusFromCode(codes.Unavailable))
+	require.Equal(t, http.StatusInternalServerError, HTTPStatusFromCode(codes.DataLoss))
+	require.Equal(t, http.StatusInternalServerError, HTTPStatusFromCode(codes.Code(100)))
+}
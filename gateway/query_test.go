@@ -0,0 +1,100 @@
+package gateway
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSetQueryParams(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		query  string
+		filter *pb.Filter
+		err    bool
+	}{
+		{
+			name:   "empty",
+			query:  "",
+			filter: &pb.Filter{},
+		},
+		{
+			name:  "proto_names",
+			query: "max_price_usd=2500.5&min_cpu_cores=4&min_cpu_ghz=2.2&min_ram.value=8&min_ram.unit=GIGABYTE",
+			filter: &pb.Filter{
+				MaxPriceUsd: 2500.5,
+				MinCpuCores: 4,
+				MinCpuGhz:   2.2,
+				MinRam:      &pb.Memory{Value: 8, Unit: pb.Memory_GIGABYTE},
+			},
+		},
+		{
+			name:  "json_names_and_enum_number",
+			query: "maxPriceUsd=3000&minRam.value=512&minRam.unit=4",
+			filter: &pb.Filter{
+				MaxPriceUsd: 3000,
+				MinRam:      &pb.Memory{Value: 512, Unit: pb.Memory_MEGABYTE},
+			},
+		},
+		{
+			name:  "unknown_field",
+			query: "max_weight=2",
+			err:   true,
+		},
+		{
+			name:  "unknown_nested_field",
+			query: "min_ram.size=2",
+			err:   true,
+		},
+		{
+			name:  "scalar_as_message",
+			query: "min_cpu_cores.value=2",
+			err:   true,
+		},
+		{
+			name:  "message_as_scalar",
+			query: "min_ram=8",
+			err:   true,
+		},
+		{
+			name:  "invalid_number",
+			query: "min_cpu_cores=-1",
+			err:   true,
+		},
+		{
+			name:  "unknown_enum",
+			query: "min_ram.unit=PETABYTE",
+			err:   true,
+		},
+		{
+			name:  "repeated_value",
+			query: "min_cpu_cores=2&min_cpu_cores=4",
+			err:   true,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			params, err := url.ParseQuery(tc.query)
+			require.NoError(t, err)
+
+			filter := &pb.Filter{}
+			err = setQueryParams(filter, params)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, proto.Equal(tc.filter, filter), "%v", filter)
+		})
+	}
+}
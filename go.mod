@@ -3,8 +3,14 @@ module github.com/Ruadgedy/pcbook
 go 1.16
 
 require (
-	github.com/golang/protobuf v1.5.0
+	github.com/golang-jwt/jwt/v4 v4.4.2
+	github.com/google/btree v1.0.1
 	github.com/google/uuid v1.3.0
+	github.com/mattn/go-sqlite3 v1.14.16
 	github.com/stretchr/testify v1.7.1
+	golang.org/x/crypto v0.0.0-20210616213533-5ff15b29337e
+	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013
+	google.golang.org/grpc v1.45.0
 	google.golang.org/protobuf v1.28.0
+	gopkg.in/yaml.v3 v3.0.1
 )
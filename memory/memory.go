@@ -0,0 +1,301 @@
+// Package memory provides unit conversion, comparison, normalisation and
+// formatting for pb.Memory values.
+//
+// pb.Memory only carries a unit enum, so whether one KILOBYTE is 1000 or 1024
+// bytes is a convention rather than part of the message. This package defaults
+// to binary multipliers (1 KILOBYTE = 1024 BYTE), which is what RAM and the rest
+// of this repository use; a Converter with the Decimal system can be used for
+// values such as disk capacities that follow SI multipliers.
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Ruadgedy/pcbook/pb"
+)
+
+var (
+	// ErrOverflow is returned when a converted value does not fit in an uint64
+	ErrOverflow = errors.New("memory value overflows uint64")
+	// ErrUnknownUnit is returned when a memory value has an unknown unit
+	ErrUnknownUnit = errors.New("unknown memory unit")
+	// ErrNotWholeBytes is returned when a value in bits cannot be expressed as whole bytes
+	ErrNotWholeBytes = errors.New("memory value is not a whole number of bytes")
+)
+
+// System is the multiplier between adjacent units from BYTE to TERABYTE
+type System int
+
+const (
+	// Binary uses a multiplier of 1024, e.g. 1 KILOBYTE = 1024 BYTE
+	Binary System = iota
+	// Decimal uses a multiplier of 1000, e.g. 1 KILOBYTE = 1000 BYTE
+	Decimal
+)
+
+// Converter converts memory values using the multipliers of its System
+type Converter struct {
+	System System
+}
+
+// Default is the converter used by the package-level functions, it uses binary multipliers
+var Default = &Converter{System: Binary}
+
+// units lists the known units from the smallest to the largest
+var units = []pb.Memory_Unit{
+	pb.Memory_BIT,
+	pb.Memory_BYTE,
+	pb.Memory_KILOBYTE,
+	pb.Memory_MEGABYTE,
+	pb.Memory_GIGABYTE,
+	pb.Memory_TERABYTE,
+}
+
+// NewConverter returns a converter using the given multiplier system
+func NewConverter(system System) *Converter {
+	return &Converter{System: system}
+}
+
+// multiplier returns the number of bytes in one kilobyte
+func (converter *Converter) multiplier() uint64 {
+	if converter.System == Decimal {
+		return 1000
+	}
+	return 1024
+}
+
+// BitsPerUnit returns the number of bits in one of the given unit
+func (converter *Converter) BitsPerUnit(unit pb.Memory_Unit) (uint64, error) {
+	switch unit {
+	case pb.Memory_BIT:
+		return 1, nil
+	case pb.Memory_BYTE:
+		return 8, nil
+	case pb.Memory_KILOBYTE, pb.Memory_MEGABYTE, pb.Memory_GIGABYTE, pb.Memory_TERABYTE:
+		n := uint64(8)
+		for u := pb.Memory_BYTE; u < unit; u++ {
+			n *= converter.multiplier()
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("%w: %v", ErrUnknownUnit, unit)
+	}
+}
+
+// bits128 returns the size of memory in bits as a 128-bit value
+func (converter *Converter) bits128(memory *pb.Memory) (hi, lo uint64, err error) {
+	perUnit, err := converter.BitsPerUnit(memory.GetUnit())
+	if err != nil {
+		return 0, 0, err
+	}
+	hi, lo = bits.Mul64(memory.GetValue(), perUnit)
+	return hi, lo, nil
+}
+
+// Bits returns the size of memory in bits
+func (converter *Converter) Bits(memory *pb.Memory) (uint64, error) {
+	hi, lo, err := converter.bits128(memory)
+	if err != nil {
+		return 0, err
+	}
+	if hi != 0 {
+		return 0, ErrOverflow
+	}
+	return lo, nil
+}
+
+// Bytes returns the size of memory in bytes
+func (converter *Converter) Bytes(memory *pb.Memory) (uint64, error) {
+	hi, lo, err := converter.bits128(memory)
+	if err != nil {
+		return 0, err
+	}
+	if lo%8 != 0 {
+		return 0, ErrNotWholeBytes
+	}
+	if hi >= 8 {
+		return 0, ErrOverflow
+	}
+	// (hi<<64 | lo) / 8 without losing the high bits
+	return hi<<61 | lo>>3, nil
+}
+
+// Compare compares the sizes of a and b across units, it returns -1 if a < b,
+// 0 if a == b and 1 if a > b. Nil values and unknown units count as zero.
+func (converter *Converter) Compare(a, b *pb.Memory) int {
+	aHi, aLo, err := converter.bits128(a)
+	if err != nil {
+		aHi, aLo = 0, 0
+	}
+	bHi, bLo, err := converter.bits128(b)
+	if err != nil {
+		bHi, bLo = 0, 0
+	}
+
+	switch {
+	case aHi < bHi || (aHi == bHi && aLo < bLo):
+		return -1
+	case aHi > bHi || (aHi == bHi && aLo > bLo):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Normalize returns the same size of memory expressed in the largest unit that
+// represents it exactly, e.g. 8192 MEGABYTE becomes 8 GIGABYTE. Zero keeps its unit.
+func (converter *Converter) Normalize(memory *pb.Memory) (*pb.Memory, error) {
+	hi, lo, err := converter.bits128(memory)
+	if err != nil {
+		return nil, err
+	}
+	if hi == 0 && lo == 0 {
+		return &pb.Memory{Value: 0, Unit: memory.GetUnit()}, nil
+	}
+
+	for i := len(units) - 1; i >= 0; i-- {
+		perUnit, _ := converter.BitsPerUnit(units[i])
+		// 128位除法：商必须能放进 uint64，且没有余数
+		if hi >= perUnit {
+			continue
+		}
+		quo, rem := bits.Div64(hi, lo, perUnit)
+		if rem == 0 {
+			return &pb.Memory{Value: quo, Unit: units[i]}, nil
+		}
+	}
+
+	// 以 BIT 为单位时一定整除，只有超出范围时才会走到这里
+	return nil, ErrOverflow
+}
+
+// Format returns a human-readable representation of memory in its normalized
+// unit, e.g. "16 GiB". Binary converters use IEC symbols (KiB, MiB, GiB, TiB)
+// and decimal converters use SI symbols (kB, MB, GB, TB), so the output is
+// unambiguous and can be read back with Parse.
+func (converter *Converter) Format(memory *pb.Memory) string {
+	normalized, err := converter.Normalize(memory)
+	if err != nil {
+		return fmt.Sprintf("%d %v", memory.GetValue(), memory.GetUnit())
+	}
+	return fmt.Sprintf("%d %s", normalized.GetValue(), converter.symbol(normalized.GetUnit()))
+}
+
+func (converter *Converter) symbol(unit pb.Memory_Unit) string {
+	switch unit {
+	case pb.Memory_BIT:
+		return "bit"
+	case pb.Memory_BYTE:
+		return "B"
+	}
+
+	prefix := map[pb.Memory_Unit]string{
+		pb.Memory_KILOBYTE: "K",
+		pb.Memory_MEGABYTE: "M",
+		pb.Memory_GIGABYTE: "G",
+		pb.Memory_TERABYTE: "T",
+	}[unit]
+	if converter.System == Decimal {
+		if unit == pb.Memory_KILOBYTE {
+			return "kB"
+		}
+		return prefix + "B"
+	}
+	return prefix + "iB"
+}
+
+// suffixes maps lower-case unit symbols to their unit and whether the symbol
+// is an IEC binary symbol. SI symbols follow the converter's System.
+var suffixes = map[string]struct {
+	unit pb.Memory_Unit
+	iec  bool
+}{
+	"bit": {pb.Memory_BIT, false}, "bits": {pb.Memory_BIT, false},
+	"byte": {pb.Memory_BYTE, false}, "bytes": {pb.Memory_BYTE, false},
+	"k": {pb.Memory_KILOBYTE, false}, "kb": {pb.Memory_KILOBYTE, false}, "kib": {pb.Memory_KILOBYTE, true},
+	"m": {pb.Memory_MEGABYTE, false}, "mb": {pb.Memory_MEGABYTE, false}, "mib": {pb.Memory_MEGABYTE, true},
+	"g": {pb.Memory_GIGABYTE, false}, "gb": {pb.Memory_GIGABYTE, false}, "gib": {pb.Memory_GIGABYTE, true},
+	"t": {pb.Memory_TERABYTE, false}, "tb": {pb.Memory_TERABYTE, false}, "tib": {pb.Memory_TERABYTE, true},
+}
+
+// Parse parses a human-readable memory size such as "16 GB", "512MiB" or "8bit".
+//
+// Symbols are case-insensitive except for the single letters "b" (bit) and
+// "B" (byte). SI symbols (kB, MB, GB, TB) map directly onto the memory unit and
+// so follow the converter's System; IEC symbols (KiB, MiB, GiB, TiB) are always
+// binary and, with a decimal converter, the result is converted and normalized.
+func (converter *Converter) Parse(s string) (*pb.Memory, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsDigit(r) })
+	if i <= 0 {
+		return nil, fmt.Errorf("invalid memory size %q: missing value", s)
+	}
+
+	value, err := strconv.ParseUint(s[:i], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid memory size %q: %w", s, err)
+	}
+
+	symbol := strings.TrimSpace(s[i:])
+	var unit pb.Memory_Unit
+	iec := false
+	switch symbol {
+	case "b":
+		unit = pb.Memory_BIT
+	case "B":
+		unit = pb.Memory_BYTE
+	default:
+		suffix, ok := suffixes[strings.ToLower(symbol)]
+		if !ok {
+			return nil, fmt.Errorf("invalid memory size %q: %w %q", s, ErrUnknownUnit, symbol)
+		}
+		unit, iec = suffix.unit, suffix.iec
+	}
+
+	memory := &pb.Memory{Value: value, Unit: unit}
+	if !iec || converter.System == Binary {
+		return memory, nil
+	}
+
+	// 十进制换算下的IEC单位：先按二进制换算成字节数，再用十进制单位归一化
+	bytes, err := Default.Bytes(memory)
+	if err != nil {
+		return nil, fmt.Errorf("invalid memory size %q: %w", s, err)
+	}
+	return converter.Normalize(&pb.Memory{Value: bytes, Unit: pb.Memory_BYTE})
+}
+
+// Bits returns the size of memory in bits using binary multipliers
+func Bits(memory *pb.Memory) (uint64, error) {
+	return Default.Bits(memory)
+}
+
+// Bytes returns the size of memory in bytes using binary multipliers
+func Bytes(memory *pb.Memory) (uint64, error) {
+	return Default.Bytes(memory)
+}
+
+// Compare compares the sizes of a and b using binary multipliers
+func Compare(a, b *pb.Memory) int {
+	return Default.Compare(a, b)
+}
+
+// Normalize expresses memory in its largest exact unit using binary multipliers
+func Normalize(memory *pb.Memory) (*pb.Memory, error) {
+	return Default.Normalize(memory)
+}
+
+// Format returns a human-readable representation of memory using binary multipliers
+func Format(memory *pb.Memory) string {
+	return Default.Format(memory)
+}
+
+// Parse parses a human-readable memory size using binary multipliers
+func Parse(s string) (*pb.Memory, error) {
+	return Default.Parse(s)
+}
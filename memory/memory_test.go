@@ -0,0 +1,139 @@
+package memory
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitsAndBytes(t *testing.T) {
+	t.Parallel()
+
+	n, err := Bits(&pb.Memory{Value: 2, Unit: pb.Memory_KILOBYTE})
+	require.NoError(t, err)
+	require.EqualValues(t, 2*1024*8, n)
+
+	n, err = NewConverter(Decimal).Bytes(&pb.Memory{Value: 3, Unit: pb.Memory_GIGABYTE})
+	require.NoError(t, err)
+	require.EqualValues(t, 3000000000, n)
+
+	_, err = Bits(&pb.Memory{Value: math.MaxUint64 / 8, Unit: pb.Memory_KILOBYTE})
+	require.ErrorIs(t, err, ErrOverflow)
+
+	// 位数溢出但字节数没有溢出
+	n, err = Bytes(&pb.Memory{Value: math.MaxUint64 / 1024, Unit: pb.Memory_KILOBYTE})
+	require.NoError(t, err)
+	require.Equal(t, uint64(math.MaxUint64/1024*1024), n)
+
+	_, err = Bytes(&pb.Memory{Value: 1 << 30, Unit: pb.Memory_TERABYTE})
+	require.ErrorIs(t, err, ErrOverflow)
+
+	_, err = Bytes(&pb.Memory{Value: 12, Unit: pb.Memory_BIT})
+	require.ErrorIs(t, err, ErrNotWholeBytes)
+
+	_, err = Bits(&pb.Memory{Value: 1, Unit: pb.Memory_UNKNOWN})
+	require.ErrorIs(t, err, ErrUnknownUnit)
+}
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		a        *pb.Memory
+		b        *pb.Memory
+		expected int
+	}{
+		{"equal_across_units", &pb.Memory{Value: 8, Unit: pb.Memory_GIGABYTE}, &pb.Memory{Value: 8192, Unit: pb.Memory_MEGABYTE}, 0},
+		{"less_across_units", &pb.Memory{Value: 8191, Unit: pb.Memory_MEGABYTE}, &pb.Memory{Value: 8, Unit: pb.Memory_GIGABYTE}, -1},
+		{"greater_across_units", &pb.Memory{Value: 1, Unit: pb.Memory_TERABYTE}, &pb.Memory{Value: 1023, Unit: pb.Memory_GIGABYTE}, 1},
+		{"bytes_and_bits", &pb.Memory{Value: 1, Unit: pb.Memory_BYTE}, &pb.Memory{Value: 8, Unit: pb.Memory_BIT}, 0},
+		{"no_overflow", &pb.Memory{Value: 1 << 62, Unit: pb.Memory_TERABYTE}, &pb.Memory{Value: 1 << 63, Unit: pb.Memory_GIGABYTE}, 1},
+		{"nil_is_zero", nil, &pb.Memory{Value: 0, Unit: pb.Memory_BYTE}, 0},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.expected, Compare(tc.a, tc.b))
+			require.Equal(t, -tc.expected, Compare(tc.b, tc.a))
+		})
+	}
+
+	decimal := NewConverter(Decimal)
+	require.Equal(t, 0, decimal.Compare(&pb.Memory{Value: 8, Unit: pb.Memory_GIGABYTE}, &pb.Memory{Value: 8000, Unit: pb.Memory_MEGABYTE}))
+}
+
+func TestNormalize(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		converter *Converter
+		in        *pb.Memory
+		expected  *pb.Memory
+	}{
+		{"binary_gigabyte", Default, &pb.Memory{Value: 8192, Unit: pb.Memory_MEGABYTE}, &pb.Memory{Value: 8, Unit: pb.Memory_GIGABYTE}},
+		{"binary_not_exact", Default, &pb.Memory{Value: 1536, Unit: pb.Memory_MEGABYTE}, &pb.Memory{Value: 1536, Unit: pb.Memory_MEGABYTE}},
+		{"bits_to_bytes", Default, &pb.Memory{Value: 16, Unit: pb.Memory_BIT}, &pb.Memory{Value: 2, Unit: pb.Memory_BYTE}},
+		{"decimal_terabyte", NewConverter(Decimal), &pb.Memory{Value: 2000, Unit: pb.Memory_GIGABYTE}, &pb.Memory{Value: 2, Unit: pb.Memory_TERABYTE}},
+		{"zero", Default, &pb.Memory{Value: 0, Unit: pb.Memory_MEGABYTE}, &pb.Memory{Value: 0, Unit: pb.Memory_MEGABYTE}},
+		{"huge_bits", Default, &pb.Memory{Value: math.MaxUint64, Unit: pb.Memory_TERABYTE}, &pb.Memory{Value: math.MaxUint64, Unit: pb.Memory_TERABYTE}},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			out, err := tc.converter.Normalize(tc.in)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected.GetValue(), out.GetValue())
+			require.Equal(t, tc.expected.GetUnit(), out.GetUnit())
+		})
+	}
+}
+
+func TestFormatParse(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "16 GiB", Format(&pb.Memory{Value: 16384, Unit: pb.Memory_MEGABYTE}))
+	require.Equal(t, "16 GB", NewConverter(Decimal).Format(&pb.Memory{Value: 16, Unit: pb.Memory_GIGABYTE}))
+	require.Equal(t, "3 bit", Format(&pb.Memory{Value: 3, Unit: pb.Memory_BIT}))
+	require.Equal(t, "1 UNKNOWN", Format(&pb.Memory{Value: 1, Unit: pb.Memory_UNKNOWN}))
+
+	testCases := []struct {
+		converter *Converter
+		in        string
+		expected  *pb.Memory
+	}{
+		{Default, "16 GB", &pb.Memory{Value: 16, Unit: pb.Memory_GIGABYTE}},
+		{Default, "512MiB", &pb.Memory{Value: 512, Unit: pb.Memory_MEGABYTE}},
+		{Default, " 8 b ", &pb.Memory{Value: 8, Unit: pb.Memory_BIT}},
+		{Default, "8B", &pb.Memory{Value: 8, Unit: pb.Memory_BYTE}},
+		{Default, "2 tb", &pb.Memory{Value: 2, Unit: pb.Memory_TERABYTE}},
+		{Default, "64k", &pb.Memory{Value: 64, Unit: pb.Memory_KILOBYTE}},
+		{NewConverter(Decimal), "16 GB", &pb.Memory{Value: 16, Unit: pb.Memory_GIGABYTE}},
+		{NewConverter(Decimal), "1 KiB", &pb.Memory{Value: 1024, Unit: pb.Memory_BYTE}},
+		{NewConverter(Decimal), "512MiB", &pb.Memory{Value: 536870912, Unit: pb.Memory_BYTE}},
+	}
+
+	for _, tc := range testCases {
+		out, err := tc.converter.Parse(tc.in)
+		require.NoError(t, err, tc.in)
+		require.Equal(t, tc.expected.GetValue(), out.GetValue(), tc.in)
+		require.Equal(t, tc.expected.GetUnit(), out.GetUnit(), tc.in)
+
+		// 格式化后应能解析回相同大小
+		back, err := tc.converter.Parse(tc.converter.Format(out))
+		require.NoError(t, err)
+		require.Equal(t, 0, tc.converter.Compare(out, back))
+	}
+
+	for _, in := range []string{"", "GB", "12 XB", "-1 GB", "99999999999999999999 B"} {
+		_, err := Parse(in)
+		require.Error(t, err, in)
+	}
+}
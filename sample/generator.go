@@ -1,30 +1,164 @@
 package sample
 
 import (
+	"math/rand"
+	"sync"
+	"time"
+
 	"github.com/Ruadgedy/pcbook/pb"
-	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// Generator generates sample laptops and their components.
+// Two generators created with the same seed and clock produce identical values,
+// including IDs and timestamps. It is safe for concurrent use.
+type Generator struct {
+	mutex sync.Mutex
+	rand  *rand.Rand
+	clock func() time.Time
+}
+
+// NewGenerator returns a new Generator seeded with seed, which uses clock for timestamps.
+// If clock is nil, time.Now is used.
+func NewGenerator(seed int64, clock func() time.Time) *Generator {
+	if clock == nil {
+		clock = time.Now
+	}
+	return &Generator{
+		rand:  rand.New(rand.NewSource(seed)),
+		clock: clock,
+	}
+}
+
+// defaultGenerator backs the package-level functions, it is seeded from the wall clock
+var defaultGenerator = NewGenerator(time.Now().UnixNano(), nil)
+
 // NewKeyboard returns a new sample keyboard
 func NewKeyboard() *pb.Keyboard {
+	return defaultGenerator.NewKeyboard()
+}
+
+// NewCPU returns a new sample CPU
+func NewCPU() *pb.CPU {
+	return defaultGenerator.NewCPU()
+}
+
+// NewGPU returns a new sample GPU
+func NewGPU() *pb.GPU {
+	return defaultGenerator.NewGPU()
+}
+
+// NewRAM returns a new sample RAM
+func NewRAM() *pb.Memory {
+	return defaultGenerator.NewRAM()
+}
+
+// NewSSD returns a new sample SSD
+func NewSSD() *pb.Storage {
+	return defaultGenerator.NewSSD()
+}
+
+// NewHDD returns a new sample HDD
+func NewHDD() *pb.Storage {
+	return defaultGenerator.NewHDD()
+}
+
+// NewScreen returns a new sample Screen
+func NewScreen() *pb.Screen {
+	return defaultGenerator.NewScreen()
+}
+
+// NewLaptop returns a new sample Laptop
+func NewLaptop() *pb.Laptop {
+	return defaultGenerator.NewLaptop()
+}
+
+// RandomLaptopScore returns a random laptop score
+func RandomLaptopScore() float64 {
+	return defaultGenerator.RandomLaptopScore()
+}
+
+// NewKeyboard returns a new sample keyboard
+func (g *Generator) NewKeyboard() *pb.Keyboard {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.newKeyboard()
+}
+
+// NewCPU returns a new sample CPU
+func (g *Generator) NewCPU() *pb.CPU {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.newCPU()
+}
+
+// NewGPU returns a new sample GPU
+func (g *Generator) NewGPU() *pb.GPU {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.newGPU()
+}
+
+// NewRAM returns a new sample RAM
+func (g *Generator) NewRAM() *pb.Memory {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.newRAM()
+}
+
+// NewSSD returns a new sample SSD
+func (g *Generator) NewSSD() *pb.Storage {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.newSSD()
+}
+
+// NewHDD returns a new sample HDD
+func (g *Generator) NewHDD() *pb.Storage {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.newHDD()
+}
+
+// NewScreen returns a new sample Screen
+func (g *Generator) NewScreen() *pb.Screen {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.newScreen()
+}
+
+// NewLaptop returns a new sample Laptop
+func (g *Generator) NewLaptop() *pb.Laptop {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.newLaptop()
+}
+
+// RandomLaptopScore returns a random laptop score
+func (g *Generator) RandomLaptopScore() float64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return float64(g.randomInt(1, 10))
+}
+
+func (g *Generator) newKeyboard() *pb.Keyboard {
 	keyboard := &pb.Keyboard{
-		Layout:  randomKeyboardLayout(),
-		Backlit: randomBool(),
+		Layout:  g.randomKeyboardLayout(),
+		Backlit: g.randomBool(),
 	}
 
 	return keyboard
 }
 
-// NewCPU returns a new sample CPU
-func NewCPU() *pb.CPU {
-	brand := randomCPUBrand()
-	name := randomCPUName(brand)
+func (g *Generator) newCPU() *pb.CPU {
+	brand := g.randomCPUBrand()
+	name := g.randomCPUName(brand)
 
-	numberCores := randomInt(2, 8)
-	numberThreads := randomInt(numberCores, 12)
+	numberCores := g.randomInt(2, 8)
+	numberThreads := g.randomInt(numberCores, 12)
 
-	minGhz := randomFloat64(2.0, 3.5)
-	maxGhz := randomFloat64(minGhz, 5.0)
+	minGhz := g.randomFloat64(2.0, 3.5)
+	maxGhz := g.randomFloat64(minGhz, 5.0)
 
 	cpu := &pb.CPU{
 		Brand:         brand,
@@ -38,14 +172,13 @@ func NewCPU() *pb.CPU {
 	return cpu
 }
 
-// NewGPU returns a new sample GPU
-func NewGPU() *pb.GPU {
-	brand := randomGPUBrand()
-	name := randomGPUName(brand)
+func (g *Generator) newGPU() *pb.GPU {
+	brand := g.randomGPUBrand()
+	name := g.randomGPUName(brand)
 
-	minGhz := randomFloat64(1.0, 1.5)
-	maxGhz := randomFloat64(minGhz, 2.0)
-	memGB := randomInt(2, 6)
+	minGhz := g.randomFloat64(1.0, 1.5)
+	maxGhz := g.randomFloat64(minGhz, 2.0)
+	memGB := g.randomInt(2, 6)
 
 	gpu := &pb.GPU{
 		Brand:  brand,
@@ -61,9 +194,8 @@ func NewGPU() *pb.GPU {
 	return gpu
 }
 
-// NewRAM returns a new sample RAM
-func NewRAM() *pb.Memory {
-	memGB := randomInt(4, 64)
+func (g *Generator) newRAM() *pb.Memory {
+	memGB := g.randomInt(4, 64)
 
 	ram := &pb.Memory{
 		Value: uint64(memGB),
@@ -73,9 +205,8 @@ func NewRAM() *pb.Memory {
 	return ram
 }
 
-// NewSSD returns a new sample SSD
-func NewSSD() *pb.Storage {
-	memGB := randomInt(128, 1024)
+func (g *Generator) newSSD() *pb.Storage {
+	memGB := g.randomInt(128, 1024)
 
 	ssd := &pb.Storage{
 		Driver: pb.Storage_SSD,
@@ -88,9 +219,8 @@ func NewSSD() *pb.Storage {
 	return ssd
 }
 
-// NewHDD returns a new sample HDD
-func NewHDD() *pb.Storage {
-	memTB := randomInt(1, 6)
+func (g *Generator) newHDD() *pb.Storage {
+	memTB := g.randomInt(1, 6)
 
 	hdd := &pb.Storage{
 		Driver: pb.Storage_HDD,
@@ -103,45 +233,38 @@ func NewHDD() *pb.Storage {
 	return hdd
 }
 
-// NewScreen returns a new sample Screen
-func NewScreen() *pb.Screen {
+func (g *Generator) newScreen() *pb.Screen {
 	screen := &pb.Screen{
-		SizeInch:   randomFloat32(13, 17),
-		Resolution: randomScreenResolution(),
-		Panel:      randomScreenPanel(),
-		Multitouch: randomBool(),
+		SizeInch:   g.randomFloat32(13, 17),
+		Resolution: g.randomScreenResolution(),
+		Panel:      g.randomScreenPanel(),
+		Multitouch: g.randomBool(),
 	}
 
 	return screen
 }
 
-// NewLaptop returns a new sample Laptop
-func NewLaptop() *pb.Laptop {
-	brand := randomLaptopBrand()
-	name := randomLaptopName(brand)
+func (g *Generator) newLaptop() *pb.Laptop {
+	brand := g.randomLaptopBrand()
+	name := g.randomLaptopName(brand)
 
 	laptop := &pb.Laptop{
-		Id:       randomID(),
+		Id:       g.randomID(),
 		Brand:    brand,
 		Name:     name,
-		Cpu:      NewCPU(),
-		Ram:      NewRAM(),
-		Gpus:     []*pb.GPU{NewGPU()},
-		Storages: []*pb.Storage{NewSSD(), NewHDD()},
-		Screen:   NewScreen(),
-		Keyboard: NewKeyboard(),
+		Cpu:      g.newCPU(),
+		Ram:      g.newRAM(),
+		Gpus:     []*pb.GPU{g.newGPU()},
+		Storages: []*pb.Storage{g.newSSD(), g.newHDD()},
+		Screen:   g.newScreen(),
+		Keyboard: g.newKeyboard(),
 		Weight: &pb.Laptop_WeightKg{
-			WeightKg: randomFloat64(1.0, 3.0),
+			WeightKg: g.randomFloat64(1.0, 3.0),
 		},
-		PriceUsd:    randomFloat64(1500, 3500),
-		ReleaseYear: uint32(randomInt(2015, 2019)),
-		UpdatedAt:   ptypes.TimestampNow(),
+		PriceUsd:    g.randomFloat64(1500, 3500),
+		ReleaseYear: uint32(g.randomInt(2015, 2019)),
+		UpdatedAt:   timestamppb.New(g.clock()),
 	}
 
 	return laptop
 }
-
-// RandomLaptopScore returns a random laptop score
-func RandomLaptopScore() float64 {
-	return float64(randomInt(1, 10))
-}
@@ -0,0 +1,45 @@
+package sample
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestGeneratorDeterministic(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, 4, 16, 21, 20, 16, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	g1 := NewGenerator(42, clock)
+	g2 := NewGenerator(42, clock)
+
+	for i := 0; i < 10; i++ {
+		laptop1 := g1.NewLaptop()
+		laptop2 := g2.NewLaptop()
+
+		data1, err := proto.MarshalOptions{Deterministic: true}.Marshal(laptop1)
+		require.NoError(t, err)
+		data2, err := proto.MarshalOptions{Deterministic: true}.Marshal(laptop2)
+		require.NoError(t, err)
+
+		require.Equal(t, data1, data2)
+		require.Equal(t, now, laptop1.GetUpdatedAt().AsTime())
+	}
+
+	require.Equal(t, g1.RandomLaptopScore(), g2.RandomLaptopScore())
+
+	other := NewGenerator(43, clock).NewLaptop()
+	require.NotEqual(t, NewGenerator(42, clock).NewLaptop().GetId(), other.GetId())
+}
+
+func TestGeneratorDefaultClock(t *testing.T) {
+	t.Parallel()
+
+	before := time.Now()
+	laptop := NewGenerator(1, nil).NewLaptop()
+	require.False(t, laptop.GetUpdatedAt().AsTime().Before(before.Truncate(time.Second)))
+}
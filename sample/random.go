@@ -3,44 +3,44 @@ package sample
 import (
 	"github.com/Ruadgedy/pcbook/pb"
 	"github.com/google/uuid"
-	"math/rand"
-	"time"
 )
 
-func init() {
-	rand.Seed(time.Now().UnixNano())
-}
-
-func randomStringFromSet(a ...string) string {
+func (g *Generator) randomStringFromSet(a ...string) string {
 	n := len(a)
 	if n == 0 {
 		return ""
 	}
-	return a[rand.Intn(n)]
+	return a[g.rand.Intn(n)]
 }
 
-func randomBool() bool {
-	return rand.Intn(2) == 1
+func (g *Generator) randomBool() bool {
+	return g.rand.Intn(2) == 1
 }
 
-func randomInt(min, max int) int {
-	return min + rand.Int()%(max-min+1)
+func (g *Generator) randomInt(min, max int) int {
+	return min + g.rand.Int()%(max-min+1)
 }
 
-func randomFloat64(min, max float64) float64 {
-	return min + rand.Float64()*(max-min)
+func (g *Generator) randomFloat64(min, max float64) float64 {
+	return min + g.rand.Float64()*(max-min)
 }
 
-func randomFloat32(min, max float32) float32 {
-	return min + rand.Float32()*(max-min)
+func (g *Generator) randomFloat32(min, max float32) float32 {
+	return min + g.rand.Float32()*(max-min)
 }
 
-func randomID() string {
-	return uuid.New().String()
+func (g *Generator) randomID() string {
+	// 从生成器自身的随机源读取，保证相同种子生成相同的ID
+	id, err := uuid.NewRandomFromReader(g.rand)
+	if err != nil {
+		// math/rand 的 Read 方法不会返回错误
+		panic(err)
+	}
+	return id.String()
 }
 
-func randomKeyboardLayout() pb.Keyboard_Layout {
-	switch rand.Intn(3) {
+func (g *Generator) randomKeyboardLayout() pb.Keyboard_Layout {
+	switch g.rand.Intn(3) {
 	case 1:
 		return pb.Keyboard_QWERTY
 	case 2:
@@ -50,8 +50,8 @@ func randomKeyboardLayout() pb.Keyboard_Layout {
 	}
 }
 
-func randomScreenResolution() *pb.Screen_Resolution {
-	height := randomInt(1080, 4320)
+func (g *Generator) randomScreenResolution() *pb.Screen_Resolution {
+	height := g.randomInt(1080, 4320)
 	width := height * 16 / 9
 
 	resolution := &pb.Screen_Resolution{
@@ -61,20 +61,20 @@ func randomScreenResolution() *pb.Screen_Resolution {
 	return resolution
 }
 
-func randomScreenPanel() pb.Screen_Panel {
-	if rand.Intn(2) == 1 {
+func (g *Generator) randomScreenPanel() pb.Screen_Panel {
+	if g.rand.Intn(2) == 1 {
 		return pb.Screen_IPS
 	}
 	return pb.Screen_OLED
 }
 
-func randomCPUBrand() string {
-	return randomStringFromSet("Intel", "AMD")
+func (g *Generator) randomCPUBrand() string {
+	return g.randomStringFromSet("Intel", "AMD")
 }
 
-func randomCPUName(brand string) string {
+func (g *Generator) randomCPUName(brand string) string {
 	if brand == "Intel" {
-		return randomStringFromSet(
+		return g.randomStringFromSet(
 			"Xeon E-2286M",
 			"Core i9-9980HK",
 			"Core i7-9750H",
@@ -83,20 +83,20 @@ func randomCPUName(brand string) string {
 		)
 	}
 
-	return randomStringFromSet(
+	return g.randomStringFromSet(
 		"Ryzen 7 PRO 2700U",
 		"Ryzen 5 PRO 3500U",
 		"Ryzen 3 PRO 3200GE",
 	)
 }
 
-func randomGPUBrand() string {
-	return randomStringFromSet("Nvidia", "AMD")
+func (g *Generator) randomGPUBrand() string {
+	return g.randomStringFromSet("Nvidia", "AMD")
 }
 
-func randomGPUName(brand string) string {
+func (g *Generator) randomGPUName(brand string) string {
 	if brand == "Nvidia" {
-		return randomStringFromSet(
+		return g.randomStringFromSet(
 			"RTX 2060",
 			"RTX 2070",
 			"GTX 1660-Ti",
@@ -104,7 +104,7 @@ func randomGPUName(brand string) string {
 		)
 	}
 
-	return randomStringFromSet(
+	return g.randomStringFromSet(
 		"RX 590",
 		"RX 580",
 		"RX 5700-XT",
@@ -112,18 +112,17 @@ func randomGPUName(brand string) string {
 	)
 }
 
-func randomLaptopBrand() string {
-	return randomStringFromSet("Apple", "Dell", "Lenovo")
+func (g *Generator) randomLaptopBrand() string {
+	return g.randomStringFromSet("Apple", "Dell", "Lenovo")
 }
 
-func randomLaptopName(brand string) string {
+func (g *Generator) randomLaptopName(brand string) string {
 	switch brand {
 	case "Apple":
-		return randomStringFromSet("Macbook Air", "Macbook Pro")
+		return g.randomStringFromSet("Macbook Air", "Macbook Pro")
 	case "Dell":
-		return randomStringFromSet("Latitude", "Vostro", "XPS", "Alienware")
+		return g.randomStringFromSet("Latitude", "Vostro", "XPS", "Alienware")
 	default:
-		return randomStringFromSet("Thinkpad X1", "Thinkpad P1", "Thinkpad P53")
+		return g.randomStringFromSet("Thinkpad X1", "Thinkpad P1", "Thinkpad P53")
 	}
 }
-
@@ -0,0 +1,221 @@
+package serializer
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultFileMode is the permission of new files when FileOptions.Mode is zero
+const DefaultFileMode os.FileMode = 0644
+
+// FileOptions configures how files are written
+type FileOptions struct {
+	// Mode is the permission of the written file. If it is zero, a file that already exists
+	// keeps its permission and a new file gets DefaultFileMode.
+	Mode os.FileMode
+	// Backup keeps the previous version of the file as filename + ".bak"
+	Backup bool
+	// Envelope wraps the content with a header, optional compression and a checksum
+	// trailer if it is not nil, files are unwrapped transparently on read
+	Envelope *EnvelopeOptions
+	// Validate, if not nil, checks every message before the protobuf file helpers or a
+	// MessageWriter write it. An invalid message fails the write and the file is left
+	// untouched. WriteFileAtomic writes raw data and ignores it.
+	Validate func(message proto.Message) error
+}
+
+// FileOption configures how the protobuf file helpers, such as WriteProtobufToBinaryFile, write files
+type FileOption func(options *FileOptions)
+
+// WithFileMode sets the permission of the written file
+func WithFileMode(mode os.FileMode) FileOption {
+	return func(options *FileOptions) {
+		options.Mode = mode
+	}
+}
+
+// WithBackup keeps the previous version of the file as filename + ".bak"
+func WithBackup() FileOption {
+	return func(options *FileOptions) {
+		options.Backup = true
+	}
+}
+
+// WithEnvelope wraps the content of the file with an envelope
+func WithEnvelope(envelope EnvelopeOptions) FileOption {
+	return func(options *FileOptions) {
+		options.Envelope = &envelope
+	}
+}
+
+// WithValidation checks every message with validate before it is written,
+// e.g. WithValidation(validate.Message) rejects laptops that make no sense
+func WithValidation(validate func(message proto.Message) error) FileOption {
+	return func(options *FileOptions) {
+		options.Validate = validate
+	}
+}
+
+// WriteFileAtomic writes the named file through write, atomically: the data is
+// written to a temporary file in the same directory, synced to disk, then renamed
+// over the target. A crash or an error from write leaves the previous version of
+// the file untouched.
+func WriteFileAtomic(filename string, options FileOptions, write func(w io.Writer) error) error {
+	file, err := createAtomicFile(filename, options)
+	if err != nil {
+		return err
+	}
+
+	var w io.WriteCloser = nopWriteCloser{file}
+	if options.Envelope != nil {
+		w, err = NewEnvelopeWriter(file, *options.Envelope)
+		if err != nil {
+			file.Abort()
+			return err
+		}
+	}
+
+	if err = write(w); err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		file.Abort()
+		return err
+	}
+	return file.Commit()
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// atomicFile is a temporary file that replaces its target on Commit
+type atomicFile struct {
+	*os.File
+	filename string
+	options  FileOptions
+}
+
+func createAtomicFile(filename string, options FileOptions) (*atomicFile, error) {
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+
+	// 临时文件必须与目标文件在同一目录下，rename 才是原子操作
+	file, err := ioutil.TempFile(dir, "."+base+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+
+	return &atomicFile{
+		File:     file,
+		filename: filename,
+		options:  options,
+	}, nil
+}
+
+// Commit syncs the temporary file and renames it over the target
+func (file *atomicFile) Commit() error {
+	mode := file.options.Mode
+	if mode == 0 {
+		// 覆盖已有文件时保留它的权限，避免私有文件变得对其他用户可读
+		mode = fileMode(file.filename)
+	}
+
+	err := file.Chmod(mode)
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		file.Abort()
+		return err
+	}
+
+	if err = file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	if file.options.Backup {
+		if err = backupFile(file.filename); err != nil {
+			os.Remove(file.Name())
+			return err
+		}
+	}
+
+	if err = os.Rename(file.Name(), file.filename); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	return syncDir(filepath.Dir(file.filename))
+}
+
+// Abort closes and removes the temporary file, leaving the target untouched
+func (file *atomicFile) Abort() error {
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// backupFile makes filename + ".bak" a copy of filename, if filename exists
+func backupFile(filename string) error {
+	backup := filename + ".bak"
+
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil
+	}
+
+	if err := os.Remove(backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// 优先使用硬链接，目标文件在整个过程中始终存在；不支持时退化为复制
+	if err := os.Link(filename, backup); err == nil {
+		return syncDir(filepath.Dir(backup))
+	}
+
+	return copyFile(filename, backup)
+}
+
+// copyFile atomically replaces dst with a byte-for-byte copy of src with the same permission,
+// an enveloped file is copied as it is instead of being unwrapped
+func copyFile(src string, dst string) error {
+	return WriteFileAtomic(dst, FileOptions{Mode: fileMode(src)}, func(w io.Writer) error {
+		file, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = io.Copy(w, file)
+		return err
+	})
+}
+
+func fileMode(filename string) os.FileMode {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return DefaultFileMode
+	}
+	return info.Mode().Perm()
+}
+
+// syncDir flushes the directory entry changes made by a rename to disk
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
@@ -0,0 +1,262 @@
+package serializer
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/Ruadgedy/pcbook/sample"
+	"github.com/Ruadgedy/pcbook/validate"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestWriteFileAtomicFailureKeepsPrevious(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "laptop.bin")
+
+	laptop := newTestLaptop()
+	err := WriteProtobufToBinaryFile(laptop, filename)
+	require.NoError(t, err)
+
+	// 模拟写到一半失败
+	writeErr := errors.New("disk on fire")
+	err = WriteFileAtomic(filename, FileOptions{}, func(w io.Writer) error {
+		if _, err := w.Write([]byte("half a laptop")); err != nil {
+			return err
+		}
+		return writeErr
+	})
+	require.ErrorIs(t, err, writeErr)
+
+	other := &pb.Laptop{}
+	err = ReadBinaryFileToProtobuf(filename, other)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(laptop, other))
+
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "temporary file must be removed")
+}
+
+func TestWriteFileAtomicOptions(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "laptop.json")
+	options := FileOptions{Mode: 0600, Backup: true}
+
+	write := func(content string) error {
+		return WriteFileAtomic(filename, options, func(w io.Writer) error {
+			_, err := io.WriteString(w, content)
+			return err
+		})
+	}
+
+	require.NoError(t, write("first"))
+	_, err := os.Stat(filename + ".bak")
+	require.True(t, os.IsNotExist(err), "no backup without a previous version")
+
+	require.NoError(t, write("second"))
+	require.NoError(t, write("third"))
+
+	data, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "third", string(data))
+
+	data, err = ioutil.ReadFile(filename + ".bak")
+	require.NoError(t, err)
+	require.Equal(t, "second", string(data))
+
+	info, err := os.Stat(filename)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestWriteFileAtomicKeepsMode(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	laptop := newTestLaptop()
+
+	// 新文件使用默认权限
+	created := filepath.Join(dir, "created.bin")
+	require.NoError(t, WriteProtobufToBinaryFile(laptop, created))
+	info, err := os.Stat(created)
+	require.NoError(t, err)
+	require.Equal(t, DefaultFileMode, info.Mode().Perm())
+
+	// 覆盖已有文件时保留它的权限
+	private := filepath.Join(dir, "private.bin")
+	require.NoError(t, ioutil.WriteFile(private, nil, 0600))
+	require.NoError(t, os.Chmod(private, 0600))
+	require.NoError(t, WriteProtobufToBinaryFile(laptop, private))
+	info, err = os.Stat(private)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// 指定的权限优先
+	require.NoError(t, WriteProtobufToBinaryFile(laptop, private, WithFileMode(0640)))
+	info, err = os.Stat(private)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0640), info.Mode().Perm())
+}
+
+func TestCopyFileKeepsEnvelope(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "laptop.bin")
+	envelope := EnvelopeOptions{Compression: CompressionGzip, Checksum: ChecksumSHA256}
+	require.NoError(t, WriteProtobufToBinaryFile(newTestLaptop(), filename, WithEnvelope(envelope), WithFileMode(0600)))
+
+	// 硬链接失败时备份退化为复制，必须复制原始字节而不是解开信封后的数据
+	backup := filepath.Join(dir, "laptop.bin.bak")
+	require.NoError(t, copyFile(filename, backup))
+
+	original, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	copied, err := ioutil.ReadFile(backup)
+	require.NoError(t, err)
+	require.Equal(t, original, copied)
+
+	info, err := os.Stat(backup)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestWriteProtobufFileOptions(t *testing.T) {
+	t.Parallel()
+
+	first := newTestLaptop()
+	second := sample.NewLaptop()
+	opts := []FileOption{
+		WithFileMode(0600),
+		WithBackup(),
+		WithEnvelope(EnvelopeOptions{Compression: CompressionGzip, Checksum: ChecksumSHA256}),
+	}
+
+	testCases := []struct {
+		name     string
+		filename string
+		write    func(message proto.Message, filename string, opts ...FileOption) error
+	}{
+		{"binary", "laptop.bin", WriteProtobufToBinaryFile},
+		{"json", "laptop.json", WriteProtobufToJSONFile},
+		{"text", "laptop.txtpb", WriteProtobufToTextFile},
+		{"yaml", "laptop.yaml", WriteProtobufToYAMLFile},
+		{"format", "laptop.pb", WriteFile},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			filename := filepath.Join(t.TempDir(), tc.filename)
+			require.NoError(t, tc.write(first, filename, opts...))
+			require.NoError(t, tc.write(second, filename, opts...))
+
+			info, err := os.Stat(filename)
+			require.NoError(t, err)
+			require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+			file, err := os.Open(filename)
+			require.NoError(t, err)
+			_, err = NewEnvelopeReader(file)
+			require.NoError(t, err, "file must be wrapped in an envelope")
+			require.NoError(t, file.Close())
+
+			found := &pb.Laptop{}
+			require.NoError(t, ReadFile(filename, found))
+			require.True(t, proto.Equal(second, found))
+
+			backup := filename + ".bak"
+			require.NoError(t, os.Rename(backup, filename))
+			require.NoError(t, ReadFile(filename, found))
+			require.True(t, proto.Equal(first, found))
+		})
+	}
+}
+
+func TestWriteProtobufFileValidation(t *testing.T) {
+	t.Parallel()
+
+	filename := filepath.Join(t.TempDir(), "laptop.json")
+	laptop := newTestLaptop()
+	require.NoError(t, WriteFile(laptop, filename, WithValidation(validate.Message)))
+
+	invalid := sample.NewLaptop()
+	invalid.Cpu = nil
+	err := WriteFile(invalid, filename, WithValidation(validate.Message))
+	var violations validate.Violations
+	require.ErrorAs(t, err, &violations)
+	require.Equal(t, "cpu", violations[0].Field)
+
+	// 校验失败时文件保持原样
+	found := &pb.Laptop{}
+	require.NoError(t, ReadFile(filename, found))
+	require.True(t, proto.Equal(laptop, found))
+
+	// 不指定校验时照常写入
+	require.NoError(t, WriteFile(invalid, filename))
+}
+
+func TestCreateMessageFileValidation(t *testing.T) {
+	t.Parallel()
+
+	filename := filepath.Join(t.TempDir(), "laptops.bin")
+	writer, err := CreateMessageFileWithOptions(filename, FileOptions{Validate: validate.Message})
+	require.NoError(t, err)
+
+	require.NoError(t, writer.Write(sample.NewLaptop()))
+	invalid := sample.NewLaptop()
+	invalid.PriceUsd = -1
+	var violations validate.Violations
+	require.ErrorAs(t, writer.Write(invalid), &violations)
+	require.ErrorAs(t, writer.Close(), &violations)
+
+	_, err = os.Stat(filename)
+	require.True(t, os.IsNotExist(err), "an invalid message must not be written")
+}
+
+func TestCreateMessageFileAbortsOnError(t *testing.T) {
+	t.Parallel()
+
+	filename := filepath.Join(t.TempDir(), "laptops.bin")
+	generator := sample.NewGenerator(5, nil)
+
+	writer, err := CreateMessageFile(filename)
+	require.NoError(t, err)
+	require.NoError(t, writer.Write(generator.NewLaptop()))
+
+	_, err = os.Stat(filename)
+	require.True(t, os.IsNotExist(err), "file must not appear before Close")
+	require.NoError(t, writer.Close())
+
+	// 写入失败时，Close 不应替换已有的文件
+	writer, err = CreateMessageFile(filename)
+	require.NoError(t, err)
+	require.NoError(t, writer.Write(generator.NewLaptop()))
+	err = writer.Write(&pb.Laptop{Brand: string([]byte{0xff})})
+	require.Error(t, err)
+	require.Error(t, writer.Close())
+
+	reader, err := OpenMessageFile(filename)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	count := 0
+	iterator := NewLaptopIterator(reader)
+	for iterator.Next() {
+		count++
+	}
+	require.NoError(t, iterator.Err())
+	require.Equal(t, 1, count)
+}
@@ -0,0 +1,324 @@
+package serializer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// An envelope wraps the content of a file with a header and a trailer:
+//
+//	magic (4 bytes) | version (1) | compression (1) | checksum (1) | payload | length (8) | checksum (4 or 32)
+//
+// The payload is compressed as selected by the header. The trailer holds the
+// length and the checksum of the uncompressed payload, so a corrupted or
+// truncated file is reported as such instead of as a confusing unmarshal error.
+
+// envelopeMagic starts every envelope, 0x89 is not valid UTF-8 so it cannot be confused with text formats
+var envelopeMagic = []byte{0x89, 'P', 'C', 'B'}
+
+// envelopeVersion is the version of the envelope format written by this package
+const envelopeVersion = 1
+
+const envelopeHeaderSize = 7
+
+var (
+	// ErrChecksumMismatch is returned when the payload of an envelope doesn't match its checksum
+	ErrChecksumMismatch = errors.New("envelope checksum mismatch")
+	// ErrTruncatedEnvelope is returned when an envelope ends before its trailer
+	ErrTruncatedEnvelope = errors.New("truncated envelope")
+	// ErrNotEnvelope is returned when NewEnvelopeReader reads data without an envelope header
+	ErrNotEnvelope = errors.New("not an envelope")
+)
+
+// Compression is the compression algorithm of an envelope payload
+type Compression byte
+
+const (
+	// CompressionNone stores the payload as is
+	CompressionNone Compression = iota
+	// CompressionGzip compresses the payload with gzip
+	CompressionGzip
+	// CompressionFlate compresses the payload with raw DEFLATE
+	CompressionFlate
+)
+
+// Checksum is the checksum algorithm of an envelope trailer
+type Checksum byte
+
+const (
+	// ChecksumCRC32 uses CRC-32 with the IEEE polynomial
+	ChecksumCRC32 Checksum = iota
+	// ChecksumSHA256 uses SHA-256
+	ChecksumSHA256
+)
+
+func (checksum Checksum) new() (hash.Hash, error) {
+	switch checksum {
+	case ChecksumCRC32:
+		return crc32.NewIEEE(), nil
+	case ChecksumSHA256:
+		return sha256.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported envelope checksum %d", checksum)
+	}
+}
+
+func (checksum Checksum) size() int {
+	if checksum == ChecksumSHA256 {
+		return sha256.Size
+	}
+	return crc32.Size
+}
+
+// EnvelopeOptions configures the envelope of a file
+type EnvelopeOptions struct {
+	Compression Compression
+	Checksum    Checksum
+}
+
+type envelopeWriter struct {
+	writer     io.Writer
+	compressor io.WriteCloser
+	hash       hash.Hash
+	checksum   Checksum
+	length     uint64
+}
+
+// NewEnvelopeWriter writes the envelope header to w and returns a writer for the payload.
+// Close must be called to write the trailer, it doesn't close w.
+func NewEnvelopeWriter(w io.Writer, options EnvelopeOptions) (io.WriteCloser, error) {
+	hash, err := options.Checksum.new()
+	if err != nil {
+		return nil, err
+	}
+
+	writer := &envelopeWriter{
+		writer:   w,
+		hash:     hash,
+		checksum: options.Checksum,
+	}
+
+	switch options.Compression {
+	case CompressionNone:
+	case CompressionGzip:
+		writer.compressor = gzip.NewWriter(w)
+	case CompressionFlate:
+		writer.compressor, err = flate.NewWriter(w, flate.DefaultCompression)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported envelope compression %d", options.Compression)
+	}
+
+	header := append(append([]byte{}, envelopeMagic...), envelopeVersion, byte(options.Compression), byte(options.Checksum))
+	if _, err = w.Write(header); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (writer *envelopeWriter) Write(p []byte) (int, error) {
+	writer.hash.Write(p)
+	writer.length += uint64(len(p))
+
+	if writer.compressor != nil {
+		return writer.compressor.Write(p)
+	}
+	return writer.writer.Write(p)
+}
+
+// Close flushes the compressed payload and writes the trailer
+func (writer *envelopeWriter) Close() error {
+	if writer.compressor != nil {
+		if err := writer.compressor.Close(); err != nil {
+			return err
+		}
+	}
+
+	trailer := make([]byte, 8, 8+writer.checksum.size())
+	binary.BigEndian.PutUint64(trailer, writer.length)
+	trailer = writer.hash.Sum(trailer)
+
+	_, err := writer.writer.Write(trailer)
+	return err
+}
+
+// UnwrapEnvelope returns a reader of the payload if r starts with an envelope
+// header, and a reader of r itself otherwise
+func UnwrapEnvelope(r io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(r)
+	magic, err := buffered.Peek(len(envelopeMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if !bytes.Equal(magic, envelopeMagic) {
+		return buffered, nil
+	}
+	return NewEnvelopeReader(buffered)
+}
+
+// NewEnvelopeReader reads the envelope header from r and returns a reader of the
+// uncompressed payload. When the payload is fully read, the reader checks the
+// trailer and returns an error wrapping ErrChecksumMismatch or ErrTruncatedEnvelope
+// instead of io.EOF if the payload is corrupted or truncated.
+func NewEnvelopeReader(r io.Reader) (io.Reader, error) {
+	header := make([]byte, envelopeHeaderSize)
+	if n, err := io.ReadFull(r, header); err != nil {
+		if n >= len(envelopeMagic) && bytes.Equal(header[:len(envelopeMagic)], envelopeMagic) {
+			return nil, fmt.Errorf("%w: incomplete header", ErrTruncatedEnvelope)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrNotEnvelope
+		}
+		return nil, err
+	}
+	if !bytes.Equal(header[:len(envelopeMagic)], envelopeMagic) {
+		return nil, ErrNotEnvelope
+	}
+	if version := header[4]; version != envelopeVersion {
+		return nil, fmt.Errorf("unsupported envelope version %d", version)
+	}
+
+	checksum := Checksum(header[6])
+	hash, err := checksum.new()
+	if err != nil {
+		return nil, err
+	}
+
+	// 保留末尾的trailer，只把payload交给解压器
+	payload := &holdbackReader{reader: r, size: 8 + checksum.size()}
+
+	reader := &envelopeReader{
+		payload: payload,
+		hash:    hash,
+	}
+
+	switch compression := Compression(header[5]); compression {
+	case CompressionNone:
+		reader.reader = payload
+	case CompressionGzip:
+		gz, err := gzip.NewReader(bufio.NewReader(payload))
+		if err != nil {
+			return nil, reader.payloadError(err)
+		}
+		gz.Multistream(false)
+		reader.reader = gz
+	case CompressionFlate:
+		reader.reader = flate.NewReader(bufio.NewReader(payload))
+	default:
+		return nil, fmt.Errorf("unsupported envelope compression %d", compression)
+	}
+
+	return reader, nil
+}
+
+type envelopeReader struct {
+	reader  io.Reader
+	payload *holdbackReader
+	hash    hash.Hash
+	length  uint64
+	err     error
+}
+
+func (reader *envelopeReader) Read(p []byte) (int, error) {
+	if reader.err != nil {
+		return 0, reader.err
+	}
+
+	n, err := reader.reader.Read(p)
+	reader.hash.Write(p[:n])
+	reader.length += uint64(n)
+
+	if err == io.EOF {
+		err = reader.verify()
+	} else if err != nil {
+		err = reader.payloadError(err)
+	}
+	if err != nil {
+		reader.err = err
+	}
+	return n, err
+}
+
+// payloadError reports errors of a payload that ends early as a truncated envelope
+func (reader *envelopeReader) payloadError(err error) error {
+	if err == io.ErrUnexpectedEOF || err == io.EOF || reader.payload.truncated() {
+		return fmt.Errorf("%w: %v", ErrTruncatedEnvelope, err)
+	}
+	return fmt.Errorf("envelope payload: %w", err)
+}
+
+// verify checks the trailer once the payload has been fully read
+func (reader *envelopeReader) verify() error {
+	// 解压器可能没有读完payload，把剩余部分读完以拿到trailer
+	if _, err := io.Copy(io.Discard, reader.payload); err != nil {
+		return err
+	}
+
+	trailer := reader.payload.held
+	if reader.payload.truncated() {
+		return fmt.Errorf("%w: missing trailer", ErrTruncatedEnvelope)
+	}
+
+	length := binary.BigEndian.Uint64(trailer)
+	if length != reader.length {
+		return fmt.Errorf("%w: payload has %d bytes, trailer expects %d", ErrTruncatedEnvelope, reader.length, length)
+	}
+	if !bytes.Equal(reader.hash.Sum(nil), trailer[8:]) {
+		return ErrChecksumMismatch
+	}
+	return io.EOF
+}
+
+// holdbackReader reads from reader but always holds back the last size bytes,
+// which are left in held once reader reaches EOF
+type holdbackReader struct {
+	reader io.Reader
+	size   int
+	held   []byte
+	chunk  []byte
+	eof    bool
+}
+
+func (reader *holdbackReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	for !reader.eof && len(reader.held) <= reader.size {
+		if len(reader.chunk) < len(p) {
+			reader.chunk = make([]byte, len(p))
+		}
+		n, err := reader.reader.Read(reader.chunk[:len(p)])
+		reader.held = append(reader.held, reader.chunk[:n]...)
+		if err == io.EOF {
+			reader.eof = true
+		} else if err != nil {
+			return 0, err
+		}
+	}
+
+	available := len(reader.held) - reader.size
+	if available <= 0 {
+		return 0, io.EOF
+	}
+
+	n := copy(p, reader.held[:available])
+	reader.held = append(reader.held[:0], reader.held[n:]...)
+	return n, nil
+}
+
+// truncated reports whether reader reached EOF without enough bytes for the trailer
+func (reader *holdbackReader) truncated() bool {
+	return reader.eof && len(reader.held) < reader.size
+}
@@ -0,0 +1,155 @@
+package serializer
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/Ruadgedy/pcbook/sample"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+var envelopeTestOptions = []EnvelopeOptions{
+	{Compression: CompressionNone, Checksum: ChecksumCRC32},
+	{Compression: CompressionGzip, Checksum: ChecksumCRC32},
+	{Compression: CompressionFlate, Checksum: ChecksumSHA256},
+	{Compression: CompressionNone, Checksum: ChecksumSHA256},
+}
+
+func envelope(t *testing.T, payload []byte, options EnvelopeOptions) []byte {
+	buffer := &bytes.Buffer{}
+	writer, err := NewEnvelopeWriter(buffer, options)
+	require.NoError(t, err)
+	_, err = writer.Write(payload)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return buffer.Bytes()
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	payload := bytes.Repeat([]byte("laptop catalogue "), 1000)
+	for _, options := range envelopeTestOptions {
+		data := envelope(t, payload, options)
+		if options.Compression != CompressionNone {
+			require.Less(t, len(data), len(payload))
+		}
+
+		reader, err := UnwrapEnvelope(bytes.NewReader(data))
+		require.NoError(t, err)
+		got, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		require.Equal(t, payload, got)
+	}
+
+	empty := envelope(t, nil, EnvelopeOptions{})
+	reader, err := NewEnvelopeReader(bytes.NewReader(empty))
+	require.NoError(t, err)
+	got, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	require.Empty(t, got)
+}
+
+func TestEnvelopeCorrupted(t *testing.T) {
+	t.Parallel()
+
+	payload := bytes.Repeat([]byte("laptop catalogue "), 1000)
+	for _, options := range envelopeTestOptions {
+		data := envelope(t, payload, options)
+
+		// 修改payload中间的一个字节
+		corrupted := append([]byte{}, data...)
+		corrupted[len(corrupted)/2] ^= 0xff
+		reader, err := UnwrapEnvelope(bytes.NewReader(corrupted))
+		if err == nil {
+			_, err = ioutil.ReadAll(reader)
+		}
+		require.Error(t, err, "%+v", options)
+		if options.Compression == CompressionNone {
+			require.ErrorIs(t, err, ErrChecksumMismatch)
+		}
+
+		for _, cut := range []int{1, 5, len(data) / 2, len(data) - 8} {
+			reader, err := UnwrapEnvelope(bytes.NewReader(data[:len(data)-cut]))
+			if err == nil {
+				_, err = ioutil.ReadAll(reader)
+			}
+			require.ErrorIs(t, err, ErrTruncatedEnvelope, "%+v cut %d", options, cut)
+		}
+	}
+}
+
+func TestUnwrapEnvelopePassthrough(t *testing.T) {
+	t.Parallel()
+
+	reader, err := UnwrapEnvelope(strings.NewReader(`{"brand": "Apple"}`))
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, `{"brand": "Apple"}`, string(data))
+
+	_, err = NewEnvelopeReader(strings.NewReader("PCB"))
+	require.ErrorIs(t, err, ErrNotEnvelope)
+}
+
+func TestEnvelopeFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	laptop := newTestLaptop()
+	options := FileOptions{Envelope: &EnvelopeOptions{Compression: CompressionGzip, Checksum: ChecksumSHA256}}
+
+	filename := filepath.Join(dir, "laptop.json")
+	err := WriteFileAtomic(filename, options, func(w io.Writer) error {
+		return WriteProtobufToJSON(laptop, w)
+	})
+	require.NoError(t, err)
+
+	other := &pb.Laptop{}
+	err = ReadProtobufFromJSONFile(other, filename)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(laptop, other))
+
+	catalogue := filepath.Join(dir, "laptops.bin")
+	writer, err := CreateMessageFileWithOptions(catalogue, options)
+	require.NoError(t, err)
+	generator := sample.NewGenerator(6, nil)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, writer.Write(generator.NewLaptop()))
+	}
+	require.NoError(t, writer.Close())
+
+	count := func() (int, error) {
+		reader, err := OpenMessageFile(catalogue)
+		if err != nil {
+			return 0, err
+		}
+		defer reader.Close()
+
+		iterator := NewLaptopIterator(reader)
+		n := 0
+		for iterator.Next() {
+			n++
+		}
+		return n, iterator.Err()
+	}
+
+	n, err := count()
+	require.NoError(t, err)
+	require.Equal(t, 100, n)
+
+	// 截断文件后读取应报告明确的错误
+	info, err := os.Stat(catalogue)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(catalogue, info.Size()-10))
+
+	_, err = count()
+	require.ErrorIs(t, err, ErrTruncatedEnvelope)
+}
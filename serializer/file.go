@@ -1,61 +1,111 @@
 package serializer
 
 import (
-	"bytes"
-	"github.com/golang/protobuf/jsonpb"
-	"github.com/golang/protobuf/proto"
-	"io/ioutil"
+	"fmt"
+	"io"
+	"os"
+
+	"google.golang.org/protobuf/proto"
 )
 
 // WriteProtobufToBinaryFile writes protobuf message to binary file
-func WriteProtobufToBinaryFile(message proto.Message,filename string) error {
-	bytes, err := proto.Marshal(message)
-	if err != nil {
-		return err
-	}
-	if err = ioutil.WriteFile(filename, bytes, 0644);err != nil{
-		return err
-	}
-	return nil
+func WriteProtobufToBinaryFile(message proto.Message, filename string, opts ...FileOption) error {
+	return writeFile(filename, message, opts, func(w io.Writer) error {
+		return WriteProtobufToBinary(message, w)
+	})
 }
 
-func ReadBinaryFileToProtobuf(filename string, message proto.Message) (error) {
-	bytes, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return err
-	}
-	if err = proto.Unmarshal(bytes, message);err != nil{
-		return err
-	}
-	return nil
+// ReadBinaryFileToProtobuf reads protobuf message from binary file
+func ReadBinaryFileToProtobuf(filename string, message proto.Message) error {
+	return readFile(filename, func(r io.Reader) error {
+		return ReadBinaryToProtobuf(r, message)
+	})
 }
 
-// WriteJsonToBinaryFile writes JSON message to binary file
-func WriteProtobufToJSONFile(message proto.Message,filename string) error {
-	json, err := ProtobufToJSON(message)
-	if err != nil {
-		return err
+// WriteProtobufToJSONFile writes protobuf message to JSON file using DefaultJSONOptions
+func WriteProtobufToJSONFile(message proto.Message, filename string, opts ...FileOption) error {
+	return WriteProtobufToJSONFileWithOptions(message, filename, DefaultJSONOptions(), opts...)
+}
+
+// WriteProtobufToJSONFileWithOptions writes protobuf message to JSON file
+func WriteProtobufToJSONFileWithOptions(
+	message proto.Message,
+	filename string,
+	options JSONOptions,
+	opts ...FileOption,
+) error {
+	return writeFile(filename, message, opts, func(w io.Writer) error {
+		return WriteProtobufToJSONWithOptions(message, w, options)
+	})
+}
+
+// ReadProtobufFromJSONFile reads protobuf message from JSON file using DefaultJSONOptions
+func ReadProtobufFromJSONFile(message proto.Message, filename string) error {
+	return ReadProtobufFromJSONFileWithOptions(message, filename, DefaultJSONOptions())
+}
+
+// ReadProtobufFromJSONFileWithOptions reads protobuf message from JSON file
+func ReadProtobufFromJSONFileWithOptions(message proto.Message, filename string, options JSONOptions) error {
+	return readFile(filename, func(r io.Reader) error {
+		return ReadProtobufFromJSONWithOptions(message, r, options)
+	})
+}
+
+// WriteProtobufToTextFile writes protobuf message to protobuf text format file
+func WriteProtobufToTextFile(message proto.Message, filename string, opts ...FileOption) error {
+	return writeFile(filename, message, opts, func(w io.Writer) error {
+		return WriteProtobufToText(message, w)
+	})
+}
+
+// ReadProtobufFromTextFile reads protobuf message from protobuf text format file
+func ReadProtobufFromTextFile(message proto.Message, filename string) error {
+	return readFile(filename, func(r io.Reader) error {
+		return ReadProtobufFromText(message, r)
+	})
+}
+
+// WriteProtobufToYAMLFile writes protobuf message to YAML file
+func WriteProtobufToYAMLFile(message proto.Message, filename string, opts ...FileOption) error {
+	return writeFile(filename, message, opts, func(w io.Writer) error {
+		return WriteProtobufToYAML(message, w)
+	})
+}
+
+// ReadProtobufFromYAMLFile reads protobuf message from YAML file
+func ReadProtobufFromYAMLFile(message proto.Message, filename string) error {
+	return readFile(filename, func(r io.Reader) error {
+		return ReadProtobufFromYAML(message, r)
+	})
+}
+
+// writeFile atomically replaces the named file with the data written by write, configured by opts.
+// The message is validated before the file is created if opts set a validation.
+func writeFile(filename string, message proto.Message, opts []FileOption, write func(w io.Writer) error) error {
+	options := FileOptions{}
+	for _, opt := range opts {
+		opt(&options)
 	}
 
-	err = ioutil.WriteFile(filename, []byte(json), 0644)
-	if err != nil {
-		return err
+	if options.Validate != nil {
+		if err := options.Validate(message); err != nil {
+			return err
+		}
 	}
-	return nil
+	return WriteFileAtomic(filename, options, write)
 }
 
-func ReadProtobufFromJSONFile(message proto.Message,filename string) error {
-	data, err := ioutil.ReadFile(filename)
+// readFile opens the named file and passes it to read, unwrapping its envelope if it has one
+func readFile(filename string, read func(r io.Reader) error) error {
+	file, err := os.Open(filename)
 	if err != nil {
 		return err
 	}
+	defer file.Close()
 
-	unmarshaler := jsonpb.Unmarshaler{
-		AllowUnknownFields: true,
-	}
-	buffer := bytes.NewBuffer(data)
-	if err = unmarshaler.Unmarshal(buffer, message);err != nil {
-		return err
+	r, err := UnwrapEnvelope(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
 	}
-	return nil
+	return read(r)
 }
@@ -4,11 +4,22 @@ import (
 	"github.com/Ruadgedy/pcbook/pb"
 	"github.com/Ruadgedy/pcbook/sample"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
 	"testing"
+	"time"
 )
 
+// newTestLaptop returns the same laptop on every call, so the files written by
+// one test can be checked by another
+func newTestLaptop() *pb.Laptop {
+	clock := func() time.Time {
+		return time.Date(2022, 4, 16, 21, 20, 16, 0, time.UTC)
+	}
+	return sample.NewGenerator(20220416, clock).NewLaptop()
+}
+
 func TestProtobufToFile(t *testing.T)  {
-	laptop := sample.NewLaptop()
+	laptop := newTestLaptop()
 	err := WriteProtobufToBinaryFile(laptop, "laptop.bin")
 	assert.NoError(t,err)
 }
@@ -17,13 +28,13 @@ func TestFileToProtobuf(t *testing.T) {
 	laptop := &pb.Laptop{}
 	err := ReadBinaryFileToProtobuf("laptop.bin",laptop)
 	assert.NoError(t,err)
-	assert.NotNil(t, laptop)
+	assert.True(t, proto.Equal(newTestLaptop(), laptop))
 
 	t.Log(laptop)
 }
 
 func TestProtobufToJSON(t *testing.T)  {
-	laptop := sample.NewLaptop()
+	laptop := newTestLaptop()
 	err := WriteProtobufToJSONFile(laptop, "laptop.json")
 	assert.NoError(t,err)
 }
@@ -32,5 +43,6 @@ func TestReadJsonFileToProtobuf(t *testing.T)  {
 	laptop := &pb.Laptop{}
 	err := ReadProtobufFromJSONFile(laptop, "laptop.json")
 	assert.NoError(t,err)
+	assert.True(t, proto.Equal(newTestLaptop(), laptop))
 	t.Log(laptop)
-}
\ No newline at end of file
+}
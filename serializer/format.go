@@ -0,0 +1,114 @@
+package serializer
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Format is a file format for protobuf messages
+type Format int
+
+const (
+	// FormatBinary is the protobuf binary wire format, used for .bin and .pb files
+	FormatBinary Format = iota
+	// FormatJSON is the JSON format written by ProtobufToJSON, used for .json files
+	FormatJSON
+	// FormatText is the protobuf text format, used for .txtpb, .textproto and .pbtxt files
+	FormatText
+	// FormatYAML is YAML with the same field names as FormatJSON, used for .yaml and .yml files
+	FormatYAML
+)
+
+func (format Format) String() string {
+	switch format {
+	case FormatBinary:
+		return "binary"
+	case FormatJSON:
+		return "json"
+	case FormatText:
+		return "text"
+	case FormatYAML:
+		return "yaml"
+	default:
+		return fmt.Sprintf("Format(%d)", int(format))
+	}
+}
+
+var formatsByExtension = map[string]Format{
+	".bin":       FormatBinary,
+	".pb":        FormatBinary,
+	".json":      FormatJSON,
+	".txtpb":     FormatText,
+	".textproto": FormatText,
+	".pbtxt":     FormatText,
+	".yaml":      FormatYAML,
+	".yml":       FormatYAML,
+}
+
+// FormatFromFilename detects the format of a file from its extension
+func FormatFromFilename(filename string) (Format, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	format, ok := formatsByExtension[ext]
+	if !ok {
+		return 0, fmt.Errorf("cannot detect format of %q: unknown extension %q", filename, ext)
+	}
+	return format, nil
+}
+
+// WriteProtobuf writes protobuf message to w in the given format
+func WriteProtobuf(message proto.Message, w io.Writer, format Format) error {
+	switch format {
+	case FormatBinary:
+		return WriteProtobufToBinary(message, w)
+	case FormatJSON:
+		return WriteProtobufToJSON(message, w)
+	case FormatText:
+		return WriteProtobufToText(message, w)
+	case FormatYAML:
+		return WriteProtobufToYAML(message, w)
+	default:
+		return fmt.Errorf("unsupported format %v", format)
+	}
+}
+
+// ReadProtobuf reads protobuf message from r in the given format
+func ReadProtobuf(r io.Reader, message proto.Message, format Format) error {
+	switch format {
+	case FormatBinary:
+		return ReadBinaryToProtobuf(r, message)
+	case FormatJSON:
+		return ReadProtobufFromJSON(message, r)
+	case FormatText:
+		return ReadProtobufFromText(message, r)
+	case FormatYAML:
+		return ReadProtobufFromYAML(message, r)
+	default:
+		return fmt.Errorf("unsupported format %v", format)
+	}
+}
+
+// WriteFile writes protobuf message to the named file, in the format detected from its extension
+func WriteFile(message proto.Message, filename string, opts ...FileOption) error {
+	format, err := FormatFromFilename(filename)
+	if err != nil {
+		return err
+	}
+	return writeFile(filename, message, opts, func(w io.Writer) error {
+		return WriteProtobuf(message, w, format)
+	})
+}
+
+// ReadFile reads protobuf message from the named file, in the format detected from its extension
+func ReadFile(filename string, message proto.Message) error {
+	format, err := FormatFromFilename(filename)
+	if err != nil {
+		return err
+	}
+	return readFile(filename, func(r io.Reader) error {
+		return ReadProtobuf(r, message, format)
+	})
+}
@@ -0,0 +1,116 @@
+package serializer
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestReadWriteFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	laptop := newTestLaptop()
+
+	for _, name := range []string{"laptop.bin", "laptop.pb", "laptop.json", "laptop.txtpb", "laptop.yaml", "LAPTOP.YML"} {
+		filename := filepath.Join(dir, name)
+
+		err := WriteFile(laptop, filename)
+		require.NoError(t, err, name)
+
+		other := &pb.Laptop{}
+		err = ReadFile(filename, other)
+		require.NoError(t, err, name)
+		require.True(t, proto.Equal(laptop, other), name)
+	}
+
+	err := WriteFile(laptop, filepath.Join(dir, "laptop.xml"))
+	require.Error(t, err)
+
+	err = ReadFile(filepath.Join(dir, "laptop"), &pb.Laptop{})
+	require.Error(t, err)
+}
+
+func TestFormatFromFilename(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]Format{
+		"laptop.bin":            FormatBinary,
+		"dir/laptop.pb":         FormatBinary,
+		"laptop.json":           FormatJSON,
+		"laptop.txtpb":          FormatText,
+		"laptop.textproto":      FormatText,
+		"catalogue/laptop.yaml": FormatYAML,
+		"laptop.yml":            FormatYAML,
+	}
+	for filename, expected := range testCases {
+		format, err := FormatFromFilename(filename)
+		require.NoError(t, err, filename)
+		require.Equal(t, expected, format, filename)
+	}
+}
+
+func TestYAML(t *testing.T) {
+	t.Parallel()
+
+	laptop := newTestLaptop()
+
+	buffer := &bytes.Buffer{}
+	err := WriteProtobufToYAML(laptop, buffer)
+	require.NoError(t, err)
+
+	yaml := buffer.String()
+	require.True(t, strings.HasPrefix(yaml, "id: "), yaml)
+	require.Contains(t, yaml, "\n  number_cores: ")
+	require.Less(t, strings.Index(yaml, "brand:"), strings.Index(yaml, "cpu:"), "fields must keep their order")
+
+	other := &pb.Laptop{}
+	err = ReadProtobufFromYAML(other, buffer)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(laptop, other))
+}
+
+func TestReadHandWrittenYAML(t *testing.T) {
+	t.Parallel()
+
+	input := `
+# 手写的笔记本配置
+brand: Apple
+name: Macbook Pro
+cpu:
+  brand: Intel
+  numberCores: 8
+  number_threads: 16
+  min_ghz: 2.4
+  max_ghz: 5
+ram: &ram
+  value: 32
+  unit: GIGABYTE
+gpus:
+  - brand: AMD
+    name: Radeon Pro 5500M
+    memory: *ram
+screen:
+  panel: 1
+  multitouch: true
+weight_lb: 4.3
+release_year: 0x7e3
+`
+
+	laptop := &pb.Laptop{}
+	err := ReadProtobufFromYAML(laptop, strings.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, "Apple", laptop.GetBrand())
+	require.EqualValues(t, 8, laptop.GetCpu().GetNumberCores())
+	require.EqualValues(t, 32, laptop.GetRam().GetValue())
+	require.EqualValues(t, 32, laptop.GetGpus()[0].GetMemory().GetValue())
+	require.Equal(t, pb.Screen_IPS, laptop.GetScreen().GetPanel())
+	require.True(t, laptop.GetScreen().GetMultitouch())
+	require.Equal(t, 4.3, laptop.GetWeightLb())
+	require.EqualValues(t, 2019, laptop.GetReleaseYear())
+}
@@ -0,0 +1,84 @@
+package serializer
+
+import (
+	"io"
+	"io/ioutil"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+// WriteProtobufToBinary writes protobuf message to w in binary wire format
+func WriteProtobufToBinary(message proto.Message, w io.Writer) error {
+	data, err := proto.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// ReadBinaryToProtobuf reads all of r and parses it as a protobuf message in binary wire format
+func ReadBinaryToProtobuf(r io.Reader, message proto.Message) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(data, message)
+}
+
+// WriteProtobufToJSON writes protobuf message to w as JSON using DefaultJSONOptions
+func WriteProtobufToJSON(message proto.Message, w io.Writer) error {
+	return WriteProtobufToJSONWithOptions(message, w, DefaultJSONOptions())
+}
+
+// WriteProtobufToJSONWithOptions writes protobuf message to w as JSON
+func WriteProtobufToJSONWithOptions(message proto.Message, w io.Writer, options JSONOptions) error {
+	data, err := marshalJSON(message, options)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// ReadProtobufFromJSON reads all of r and parses it as a JSON protobuf message using DefaultJSONOptions
+func ReadProtobufFromJSON(message proto.Message, r io.Reader) error {
+	return ReadProtobufFromJSONWithOptions(message, r, DefaultJSONOptions())
+}
+
+// ReadProtobufFromJSONWithOptions reads all of r and parses it as a JSON protobuf message
+func ReadProtobufFromJSONWithOptions(message proto.Message, r io.Reader, options JSONOptions) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return JSONToProtobufWithOptions(data, message, options)
+}
+
+// textMarshaler writes one field per line, the protobuf runtime doesn't
+// guarantee stable whitespace for text format, so its output must not be
+// compared byte by byte
+var textMarshaler = prototext.MarshalOptions{
+	Multiline: true,
+	Indent:    "  ",
+}
+
+// WriteProtobufToText writes protobuf message to w in protobuf text format
+func WriteProtobufToText(message proto.Message, w io.Writer) error {
+	data, err := textMarshaler.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// ReadProtobufFromText reads all of r and parses it as a protobuf message in text format
+func ReadProtobufFromText(message proto.Message, r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return prototext.Unmarshal(data, message)
+}
@@ -0,0 +1,71 @@
+package serializer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestBinaryReaderWriter(t *testing.T) {
+	t.Parallel()
+
+	laptop := newTestLaptop()
+
+	// 通过gzip流往返，验证可以与任意 io.Reader/io.Writer 组合
+	buffer := &bytes.Buffer{}
+	gz := gzip.NewWriter(buffer)
+	err := WriteProtobufToBinary(laptop, gz)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	gr, err := gzip.NewReader(buffer)
+	require.NoError(t, err)
+
+	other := &pb.Laptop{}
+	err = ReadBinaryToProtobuf(gr, other)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(laptop, other))
+}
+
+func TestJSONReaderWriter(t *testing.T) {
+	t.Parallel()
+
+	laptop := newTestLaptop()
+
+	buffer := &bytes.Buffer{}
+	err := WriteProtobufToJSON(laptop, buffer)
+	require.NoError(t, err)
+
+	json, err := ProtobufToJSON(laptop)
+	require.NoError(t, err)
+	require.Equal(t, json, buffer.String())
+
+	other := &pb.Laptop{}
+	err = ReadProtobufFromJSON(other, buffer)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(laptop, other))
+}
+
+func TestTextReaderWriter(t *testing.T) {
+	t.Parallel()
+
+	laptop := newTestLaptop()
+
+	buffer := &bytes.Buffer{}
+	err := WriteProtobufToText(laptop, buffer)
+	require.NoError(t, err)
+	require.Contains(t, buffer.String(), "number_cores:")
+
+	other := &pb.Laptop{}
+	err = ReadProtobufFromText(other, buffer)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(laptop, other))
+
+	err = ReadProtobufFromText(&pb.Laptop{}, strings.NewReader("brand: 42"))
+	require.Error(t, err)
+}
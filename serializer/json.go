@@ -1,16 +1,105 @@
 package serializer
 
 import (
-	"github.com/golang/protobuf/jsonpb"
-	"github.com/golang/protobuf/proto"
+	"bytes"
+	"encoding/json"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// FieldNaming selects the names used for message fields in JSON
+type FieldNaming int
+
+const (
+	// ProtoNames uses the field names of the .proto files, e.g. "number_cores"
+	ProtoNames FieldNaming = iota
+	// JSONNames uses the lowerCamelCase JSON names, e.g. "numberCores"
+	JSONNames
 )
 
-func ProtobufToJSON(message proto.Message) (string,error){
-	marshaler := jsonpb.Marshaler{
-		OrigName:     true,
-		EnumsAsInts:  false,
-		EmitDefaults: true,
-		Indent:       "  ",
+// JSONOptions configures how messages are converted to and from JSON
+type JSONOptions struct {
+	// FieldNaming selects the field names to write, both names are always accepted on read
+	FieldNaming FieldNaming
+	// EmitUnpopulated writes fields that have their zero value
+	EmitUnpopulated bool
+	// EnumsAsInts writes enum values as numbers instead of their names
+	EnumsAsInts bool
+	// Indent is the indentation of each nesting level, an empty indent writes compact JSON
+	Indent string
+	// DiscardUnknown ignores unknown fields on read instead of failing
+	DiscardUnknown bool
+}
+
+// DefaultJSONOptions returns the options used by the functions without options:
+// proto field names, unpopulated fields, enum names, two-space indentation,
+// and unknown fields discarded on read
+func DefaultJSONOptions() JSONOptions {
+	return JSONOptions{
+		FieldNaming:     ProtoNames,
+		EmitUnpopulated: true,
+		EnumsAsInts:     false,
+		Indent:          "  ",
+		DiscardUnknown:  true,
+	}
+}
+
+func (options JSONOptions) marshaler() protojson.MarshalOptions {
+	return protojson.MarshalOptions{
+		UseProtoNames:   options.FieldNaming == ProtoNames,
+		EmitUnpopulated: options.EmitUnpopulated,
+		UseEnumNumbers:  options.EnumsAsInts,
+	}
+}
+
+func (options JSONOptions) unmarshaler() protojson.UnmarshalOptions {
+	return protojson.UnmarshalOptions{
+		DiscardUnknown: options.DiscardUnknown,
+	}
+}
+
+// ProtobufToJSON converts protobuf message to JSON string using DefaultJSONOptions
+func ProtobufToJSON(message proto.Message) (string, error) {
+	return ProtobufToJSONWithOptions(message, DefaultJSONOptions())
+}
+
+// ProtobufToJSONWithOptions converts protobuf message to JSON string
+func ProtobufToJSONWithOptions(message proto.Message, options JSONOptions) (string, error) {
+	data, err := marshalJSON(message, options)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// JSONToProtobuf parses JSON data into protobuf message using DefaultJSONOptions
+func JSONToProtobuf(data []byte, message proto.Message) error {
+	return JSONToProtobufWithOptions(data, message, DefaultJSONOptions())
+}
+
+// JSONToProtobufWithOptions parses JSON data into protobuf message
+func JSONToProtobufWithOptions(data []byte, message proto.Message, options JSONOptions) error {
+	return options.unmarshaler().Unmarshal(data, message)
+}
+
+// marshalJSON marshals message and lays it out with encoding/json, because
+// protojson deliberately randomizes its whitespace and the files we write
+// should be stable from one build to the next
+func marshalJSON(message proto.Message, options JSONOptions) ([]byte, error) {
+	data, err := options.marshaler().Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := &bytes.Buffer{}
+	if options.Indent == "" {
+		err = json.Compact(buffer, data)
+	} else {
+		err = json.Indent(buffer, data, "", options.Indent)
+	}
+	if err != nil {
+		return nil, err
 	}
-	return marshaler.MarshalToString(message)
+	return buffer.Bytes(), nil
 }
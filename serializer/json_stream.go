@@ -0,0 +1,217 @@
+package serializer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// StreamReader reads a stream of protobuf messages one at a time,
+// Read returns io.EOF when there are no more messages
+type StreamReader interface {
+	Read(message proto.Message) error
+}
+
+// StreamWriter writes a stream of protobuf messages one at a time,
+// Close must be called after the last message
+type StreamWriter interface {
+	Write(message proto.Message) error
+	Close() error
+}
+
+// streamJSONOptions writes compact JSON with the same field names as ProtobufToJSON
+var streamJSONOptions = func() JSONOptions {
+	options := DefaultJSONOptions()
+	options.Indent = ""
+	return options
+}()
+
+// NDJSONWriter writes protobuf messages as newline-delimited JSON, one compact message per line
+type NDJSONWriter struct {
+	writer *bufio.Writer
+}
+
+// NewNDJSONWriter returns a NDJSONWriter that writes to w.
+// Close must be called to flush the buffered data, it doesn't close w.
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	return &NDJSONWriter{
+		writer: bufio.NewWriter(w),
+	}
+}
+
+// Write writes one message followed by a newline
+func (writer *NDJSONWriter) Write(message proto.Message) error {
+	data, err := marshalJSON(message, streamJSONOptions)
+	if err != nil {
+		return err
+	}
+	if _, err = writer.writer.Write(data); err != nil {
+		return err
+	}
+	return writer.writer.WriteByte('\n')
+}
+
+// Close flushes the buffered data
+func (writer *NDJSONWriter) Close() error {
+	return writer.writer.Flush()
+}
+
+// NDJSONReader reads protobuf messages from newline-delimited JSON, blank lines are skipped
+type NDJSONReader struct {
+	reader *bufio.Reader
+	line   int
+}
+
+// NewNDJSONReader returns a NDJSONReader that reads from r
+func NewNDJSONReader(r io.Reader) *NDJSONReader {
+	return &NDJSONReader{
+		reader: bufio.NewReader(r),
+	}
+}
+
+// Read reads the message on the next non-blank line, errors report the 1-based line number
+func (reader *NDJSONReader) Read(message proto.Message) error {
+	for {
+		data, err := reader.reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("line %d: %w", reader.line+1, err)
+		}
+		if len(data) == 0 && err == io.EOF {
+			return io.EOF
+		}
+		reader.line++
+
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			if err == io.EOF {
+				return io.EOF
+			}
+			continue
+		}
+
+		if err := JSONToProtobufWithOptions(data, message, streamJSONOptions); err != nil {
+			return fmt.Errorf("line %d: %w", reader.line, err)
+		}
+		return nil
+	}
+}
+
+// JSONArrayWriter writes protobuf messages as the elements of a top-level JSON array
+type JSONArrayWriter struct {
+	writer *bufio.Writer
+	count  int
+}
+
+// NewJSONArrayWriter returns a JSONArrayWriter that writes to w.
+// Close must be called to terminate the array and flush the buffered data, it doesn't close w.
+func NewJSONArrayWriter(w io.Writer) *JSONArrayWriter {
+	return &JSONArrayWriter{
+		writer: bufio.NewWriter(w),
+	}
+}
+
+// Write writes one message as the next element of the array
+func (writer *JSONArrayWriter) Write(message proto.Message) error {
+	data, err := marshalJSON(message, streamJSONOptions)
+	if err != nil {
+		return err
+	}
+
+	separator := ",\n"
+	if writer.count == 0 {
+		separator = "[\n"
+	}
+	if _, err = writer.writer.WriteString(separator); err != nil {
+		return err
+	}
+	if _, err = writer.writer.Write(data); err != nil {
+		return err
+	}
+
+	writer.count++
+	return nil
+}
+
+// Close terminates the array and flushes the buffered data
+func (writer *JSONArrayWriter) Close() error {
+	end := "\n]\n"
+	if writer.count == 0 {
+		end = "[]\n"
+	}
+	if _, err := writer.writer.WriteString(end); err != nil {
+		return err
+	}
+	return writer.writer.Flush()
+}
+
+// JSONArrayReader reads protobuf messages from the elements of a top-level JSON array
+// without loading the whole array into memory
+type JSONArrayReader struct {
+	decoder  *json.Decoder
+	started  bool
+	finished bool
+	index    int
+}
+
+// NewJSONArrayReader returns a JSONArrayReader that reads from r
+func NewJSONArrayReader(r io.Reader) *JSONArrayReader {
+	return &JSONArrayReader{
+		decoder: json.NewDecoder(r),
+	}
+}
+
+// Read reads the next element of the array, errors report the 0-based element index.
+// It keeps returning io.EOF once the closing bracket has been read.
+func (reader *JSONArrayReader) Read(message proto.Message) error {
+	if reader.finished {
+		return io.EOF
+	}
+
+	if !reader.started {
+		token, err := reader.decoder.Token()
+		if err == io.EOF {
+			return fmt.Errorf("expected a JSON array: %w", io.ErrUnexpectedEOF)
+		}
+		if err != nil {
+			return fmt.Errorf("expected a JSON array: %w", err)
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			return fmt.Errorf("expected a JSON array, got %v", token)
+		}
+		reader.started = true
+	}
+
+	if !reader.decoder.More() {
+		token, err := reader.decoder.Token()
+		if err == io.EOF {
+			return fmt.Errorf("element %d: unterminated array: %w", reader.index, io.ErrUnexpectedEOF)
+		}
+		if err != nil {
+			return fmt.Errorf("element %d: %w", reader.index, err)
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != ']' {
+			return fmt.Errorf("element %d: unexpected %v", reader.index, token)
+		}
+		reader.finished = true
+		return io.EOF
+	}
+
+	var data json.RawMessage
+	if err := reader.decoder.Decode(&data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("element %d: %w", reader.index, err)
+	}
+
+	if err := JSONToProtobufWithOptions(data, message, streamJSONOptions); err != nil {
+		return fmt.Errorf("element %d: %w", reader.index, err)
+	}
+
+	reader.index++
+	return nil
+}
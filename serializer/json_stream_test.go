@@ -0,0 +1,137 @@
+package serializer
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/Ruadgedy/pcbook/sample"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestNDJSON(t *testing.T) {
+	t.Parallel()
+
+	generator := sample.NewGenerator(3, nil)
+	laptops := []*pb.Laptop{generator.NewLaptop(), generator.NewLaptop(), generator.NewLaptop()}
+
+	buffer := &bytes.Buffer{}
+	writer := NewNDJSONWriter(buffer)
+	for _, laptop := range laptops {
+		require.NoError(t, writer.Write(laptop))
+	}
+	require.NoError(t, writer.Close())
+
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	require.Len(t, lines, len(laptops))
+	for _, line := range lines {
+		require.True(t, json.Valid([]byte(line)))
+		require.Contains(t, line, `"number_cores"`)
+	}
+
+	iterator := NewLaptopIterator(NewNDJSONReader(buffer))
+	count := 0
+	for iterator.Next() {
+		require.True(t, proto.Equal(laptops[count], iterator.Laptop()))
+		count++
+	}
+	require.NoError(t, iterator.Err())
+	require.Equal(t, len(laptops), count)
+}
+
+func TestNDJSONReaderErrors(t *testing.T) {
+	t.Parallel()
+
+	input := "{\"brand\": \"Apple\"}\n\n{\"brand\": \"Dell\"}\n{\"brand\": 42}\n"
+	reader := NewNDJSONReader(strings.NewReader(input))
+
+	laptop := &pb.Laptop{}
+	require.NoError(t, reader.Read(laptop))
+	require.Equal(t, "Apple", laptop.GetBrand())
+
+	laptop = &pb.Laptop{}
+	require.NoError(t, reader.Read(laptop))
+	require.Equal(t, "Dell", laptop.GetBrand())
+
+	err := reader.Read(&pb.Laptop{})
+	require.Error(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), "line 4: "), err.Error())
+
+	// 最后一行没有换行符
+	reader = NewNDJSONReader(strings.NewReader(`{"brand": "Lenovo"}`))
+	require.NoError(t, reader.Read(&pb.Laptop{}))
+	require.Equal(t, io.EOF, reader.Read(&pb.Laptop{}))
+}
+
+func TestJSONArray(t *testing.T) {
+	t.Parallel()
+
+	generator := sample.NewGenerator(4, nil)
+	laptops := []*pb.Laptop{generator.NewLaptop(), generator.NewLaptop()}
+
+	buffer := &bytes.Buffer{}
+	writer := NewJSONArrayWriter(buffer)
+	for _, laptop := range laptops {
+		require.NoError(t, writer.Write(laptop))
+	}
+	require.NoError(t, writer.Close())
+
+	var elements []json.RawMessage
+	require.NoError(t, json.Unmarshal(buffer.Bytes(), &elements))
+	require.Len(t, elements, len(laptops))
+
+	reader := NewJSONArrayReader(buffer)
+	iterator := NewLaptopIterator(reader)
+	count := 0
+	for iterator.Next() {
+		require.True(t, proto.Equal(laptops[count], iterator.Laptop()))
+		count++
+	}
+	require.NoError(t, iterator.Err())
+	require.Equal(t, len(laptops), count)
+
+	// 读完之后继续返回 io.EOF
+	require.Equal(t, io.EOF, reader.Read(&pb.Laptop{}))
+	require.Equal(t, io.EOF, reader.Read(&pb.Laptop{}))
+
+	empty := &bytes.Buffer{}
+	require.NoError(t, NewJSONArrayWriter(empty).Close())
+	require.Equal(t, "[]\n", empty.String())
+	reader = NewJSONArrayReader(empty)
+	require.Equal(t, io.EOF, reader.Read(&pb.Laptop{}))
+	require.Equal(t, io.EOF, reader.Read(&pb.Laptop{}))
+}
+
+func TestJSONArrayReaderErrors(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"not_array", `{"brand": "Apple"}`, "expected a JSON array"},
+		{"bad_element", `[{"brand": "Apple"}, {"cpu": "fast"}]`, "element 1: "},
+		{"truncated", `[{"brand": "Apple"}, {"brand": `, "element 1: "},
+		{"unterminated", `[{"brand": "Apple"}`, "element 1: "},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			reader := NewJSONArrayReader(strings.NewReader(tc.input))
+			var err error
+			for err == nil {
+				err = reader.Read(&pb.Laptop{})
+			}
+			require.NotEqual(t, io.EOF, err)
+			require.Contains(t, err.Error(), tc.expected)
+		})
+	}
+}
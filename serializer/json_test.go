@@ -0,0 +1,77 @@
+package serializer
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestProtobufToJSONWithOptions(t *testing.T) {
+	t.Parallel()
+
+	laptop := newTestLaptop()
+	laptop.Keyboard.Backlit = false
+
+	json, err := ProtobufToJSON(laptop)
+	require.NoError(t, err)
+	require.Contains(t, json, "\n    \"number_cores\": ")
+	require.Contains(t, json, `"backlit": false`)
+	require.Contains(t, json, `"panel": "`)
+
+	options := JSONOptions{
+		FieldNaming: JSONNames,
+		EnumsAsInts: true,
+	}
+	json, err = ProtobufToJSONWithOptions(laptop, options)
+	require.NoError(t, err)
+	require.NotContains(t, json, "\n")
+	require.Contains(t, json, `"numberCores":`)
+	require.NotContains(t, json, `"backlit"`)
+	require.NotContains(t, json, `"panel":"`)
+
+	other := &pb.Laptop{}
+	err = JSONToProtobufWithOptions([]byte(json), other, options)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(laptop, other))
+
+	again, err := ProtobufToJSONWithOptions(laptop, options)
+	require.NoError(t, err)
+	require.Equal(t, json, again)
+}
+
+func TestJSONToProtobufUnknownFields(t *testing.T) {
+	t.Parallel()
+
+	data := []byte(`{"brand": "Apple", "color": "silver"}`)
+
+	laptop := &pb.Laptop{}
+	err := JSONToProtobuf(data, laptop)
+	require.NoError(t, err)
+	require.Equal(t, "Apple", laptop.GetBrand())
+
+	err = JSONToProtobufWithOptions(data, &pb.Laptop{}, JSONOptions{})
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(), "color"))
+}
+
+func TestJSONFileWithOptions(t *testing.T) {
+	t.Parallel()
+
+	filename := filepath.Join(t.TempDir(), "laptop.json")
+	options := DefaultJSONOptions()
+	options.Indent = "\t"
+	options.FieldNaming = JSONNames
+
+	laptop := newTestLaptop()
+	err := WriteProtobufToJSONFileWithOptions(laptop, filename, options)
+	require.NoError(t, err)
+
+	other := &pb.Laptop{}
+	err = ReadProtobufFromJSONFileWithOptions(other, filename, options)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(laptop, other))
+}
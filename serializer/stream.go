@@ -0,0 +1,264 @@
+package serializer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// MaxRecordSize is the largest message a MessageReader accepts, it protects
+// readers from allocating huge buffers for corrupted length prefixes
+const MaxRecordSize = 64 << 20
+
+var (
+	// ErrTruncatedRecord is returned when a stream ends in the middle of a record
+	ErrTruncatedRecord = errors.New("truncated record")
+	// ErrRecordTooLarge is returned when a record is larger than MaxRecordSize
+	ErrRecordTooLarge = errors.New("record too large")
+)
+
+// MessageWriter writes protobuf messages to a stream, each message is prefixed
+// with its size encoded as a varint, so many messages can be stored in one file
+type MessageWriter struct {
+	writer   *bufio.Writer
+	file     *atomicFile
+	envelope io.WriteCloser
+	validate func(message proto.Message) error
+	buffer   []byte
+	err      error
+}
+
+// NewMessageWriter returns a MessageWriter that writes to w.
+// Close must be called to flush the buffered data, it doesn't close w.
+func NewMessageWriter(w io.Writer) *MessageWriter {
+	return &MessageWriter{
+		writer: bufio.NewWriter(w),
+	}
+}
+
+// CreateMessageFile returns a MessageWriter that atomically replaces the named file.
+// Close must be called to flush the buffered data and replace the file.
+func CreateMessageFile(filename string) (*MessageWriter, error) {
+	return CreateMessageFileWithOptions(filename, FileOptions{})
+}
+
+// CreateMessageFileWithOptions returns a MessageWriter that atomically replaces the named file.
+// The messages are written to a temporary file which only replaces the named file when Close
+// succeeds, so the previous version is kept if any Write fails or the process crashes.
+func CreateMessageFileWithOptions(filename string, options FileOptions) (*MessageWriter, error) {
+	file, err := createAtomicFile(filename, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.Envelope == nil {
+		writer := NewMessageWriter(file)
+		writer.file = file
+		writer.validate = options.Validate
+		return writer, nil
+	}
+
+	envelope, err := NewEnvelopeWriter(file, *options.Envelope)
+	if err != nil {
+		file.Abort()
+		return nil, err
+	}
+
+	writer := NewMessageWriter(envelope)
+	writer.file = file
+	writer.envelope = envelope
+	writer.validate = options.Validate
+	return writer, nil
+}
+
+// Write writes one length-delimited message
+func (writer *MessageWriter) Write(message proto.Message) error {
+	if writer.err != nil {
+		return writer.err
+	}
+
+	if writer.validate != nil {
+		if err := writer.validate(message); err != nil {
+			return writer.fail(err)
+		}
+	}
+
+	data, err := proto.MarshalOptions{}.MarshalAppend(writer.buffer[:0], message)
+	if err != nil {
+		return writer.fail(err)
+	}
+	writer.buffer = data
+
+	var prefix [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(prefix[:], uint64(len(data)))
+	if _, err = writer.writer.Write(prefix[:n]); err != nil {
+		return writer.fail(err)
+	}
+	if _, err = writer.writer.Write(data); err != nil {
+		return writer.fail(err)
+	}
+	return nil
+}
+
+// fail records the first error, a writer that failed doesn't replace its file on Close
+func (writer *MessageWriter) fail(err error) error {
+	writer.err = err
+	return err
+}
+
+// Close flushes the buffered data. If the writer was created by CreateMessageFile, it
+// replaces the file when every Write succeeded and discards the written data otherwise.
+func (writer *MessageWriter) Close() error {
+	err := writer.err
+	if err == nil {
+		err = writer.writer.Flush()
+	}
+	if err == nil && writer.envelope != nil {
+		err = writer.envelope.Close()
+	}
+
+	if writer.file == nil {
+		return err
+	}
+	if err != nil {
+		writer.file.Abort()
+		return err
+	}
+	return writer.file.Commit()
+}
+
+// MessageReader reads length-delimited protobuf messages written by a MessageWriter
+type MessageReader struct {
+	reader *bufio.Reader
+	closer io.Closer
+	buffer []byte
+	index  int
+}
+
+// NewMessageReader returns a MessageReader that reads from r
+func NewMessageReader(r io.Reader) *MessageReader {
+	return &MessageReader{
+		reader: bufio.NewReader(r),
+	}
+}
+
+// OpenMessageFile opens the named file and returns a MessageReader reading from it,
+// the envelope of the file is unwrapped if it has one. Close must be called to close the file.
+func OpenMessageFile(filename string) (*MessageReader, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := UnwrapEnvelope(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	reader := NewMessageReader(r)
+	reader.closer = file
+	return reader, nil
+}
+
+// Read reads the next message into message. It returns io.EOF when there are no
+// more messages, and an error wrapping ErrTruncatedRecord if the stream ends in
+// the middle of a record.
+func (reader *MessageReader) Read(message proto.Message) error {
+	size, err := binary.ReadUvarint(reader.reader)
+	if err == io.EOF {
+		return io.EOF
+	}
+	if err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("record %d: %w: incomplete size prefix", reader.index, ErrTruncatedRecord)
+	}
+	if err != nil {
+		return fmt.Errorf("record %d: cannot read size prefix: %w", reader.index, err)
+	}
+	if size > MaxRecordSize {
+		return fmt.Errorf("record %d: %w: %d bytes", reader.index, ErrRecordTooLarge, size)
+	}
+
+	if uint64(cap(reader.buffer)) < size {
+		reader.buffer = make([]byte, size)
+	}
+	data := reader.buffer[:size]
+
+	n, err := io.ReadFull(reader.reader, data)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("record %d: %w: got %d of %d bytes", reader.index, ErrTruncatedRecord, n, size)
+	}
+	if err != nil {
+		return fmt.Errorf("record %d: %w", reader.index, err)
+	}
+
+	if err = proto.Unmarshal(data, message); err != nil {
+		return fmt.Errorf("record %d: %w", reader.index, err)
+	}
+
+	reader.index++
+	return nil
+}
+
+// Close closes the file if the reader was created by OpenMessageFile
+func (reader *MessageReader) Close() error {
+	if reader.closer != nil {
+		return reader.closer.Close()
+	}
+	return nil
+}
+
+// LaptopIterator iterates over the laptops of a StreamReader one at a time:
+//
+//	for iterator.Next() {
+//		laptop := iterator.Laptop()
+//	}
+//	if err := iterator.Err(); err != nil {
+//	}
+type LaptopIterator struct {
+	reader StreamReader
+	laptop *pb.Laptop
+	err    error
+}
+
+// NewLaptopIterator returns a LaptopIterator reading from reader, which can be
+// a MessageReader, a NDJSONReader or a JSONArrayReader
+func NewLaptopIterator(reader StreamReader) *LaptopIterator {
+	return &LaptopIterator{reader: reader}
+}
+
+// Next reads the next laptop, it returns false when there are no more laptops or an error occurred
+func (iterator *LaptopIterator) Next() bool {
+	if iterator.err != nil {
+		return false
+	}
+
+	laptop := &pb.Laptop{}
+	err := iterator.reader.Read(laptop)
+	if err != nil {
+		if err != io.EOF {
+			iterator.err = err
+		}
+		iterator.laptop = nil
+		return false
+	}
+
+	iterator.laptop = laptop
+	return true
+}
+
+// Laptop returns the laptop read by the last call to Next
+func (iterator *LaptopIterator) Laptop() *pb.Laptop {
+	return iterator.laptop
+}
+
+// Err returns the error that stopped the iteration, it is nil if all laptops were read
+func (iterator *LaptopIterator) Err() error {
+	return iterator.err
+}
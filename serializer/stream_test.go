@@ -0,0 +1,94 @@
+package serializer
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/Ruadgedy/pcbook/sample"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMessageFile(t *testing.T) {
+	t.Parallel()
+
+	filename := filepath.Join(t.TempDir(), "laptops.bin")
+	generator := sample.NewGenerator(1, nil)
+
+	writer, err := CreateMessageFile(filename)
+	require.NoError(t, err)
+
+	laptops := make([]*pb.Laptop, 1000)
+	for i := range laptops {
+		laptops[i] = generator.NewLaptop()
+		err = writer.Write(laptops[i])
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+
+	reader, err := OpenMessageFile(filename)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	iterator := NewLaptopIterator(reader)
+	count := 0
+	for iterator.Next() {
+		require.True(t, proto.Equal(laptops[count], iterator.Laptop()))
+		count++
+	}
+	require.NoError(t, iterator.Err())
+	require.Equal(t, len(laptops), count)
+	require.False(t, iterator.Next())
+}
+
+func TestMessageReaderTruncated(t *testing.T) {
+	t.Parallel()
+
+	buffer := &bytes.Buffer{}
+	writer := NewMessageWriter(buffer)
+	generator := sample.NewGenerator(2, nil)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, writer.Write(generator.NewLaptop()))
+	}
+	require.NoError(t, writer.Close())
+	data := buffer.Bytes()
+
+	// 截断最后一条记录的数据部分
+	iterator := NewLaptopIterator(NewMessageReader(bytes.NewReader(data[:len(data)-5])))
+	count := 0
+	for iterator.Next() {
+		count++
+	}
+	require.Equal(t, 2, count)
+	require.ErrorIs(t, iterator.Err(), ErrTruncatedRecord)
+	require.Contains(t, iterator.Err().Error(), "record 2")
+
+	// 只剩下不完整的长度前缀
+	reader := NewMessageReader(bytes.NewReader([]byte{0x80}))
+	err := reader.Read(&pb.Laptop{})
+	require.ErrorIs(t, err, ErrTruncatedRecord)
+
+	// 空文件没有记录
+	reader = NewMessageReader(bytes.NewReader(nil))
+	err = reader.Read(&pb.Laptop{})
+	require.Equal(t, io.EOF, err)
+}
+
+func TestMessageReaderTooLarge(t *testing.T) {
+	t.Parallel()
+
+	reader := NewMessageReader(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff, 0x0f}))
+	err := reader.Read(&pb.Laptop{})
+	require.ErrorIs(t, err, ErrRecordTooLarge)
+}
+
+func TestOpenMessageFileMissing(t *testing.T) {
+	t.Parallel()
+
+	_, err := OpenMessageFile(filepath.Join(t.TempDir(), "missing.bin"))
+	require.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,209 @@
+package serializer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// WriteProtobufToYAML writes protobuf message to w as YAML. The document has the
+// same structure and field names as the JSON written by ProtobufToJSON.
+func WriteProtobufToYAML(message proto.Message, w io.Writer) error {
+	data, err := marshalJSON(message, streamJSONOptions)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	node, err := jsonToYAMLNode(decoder)
+	if err != nil {
+		return err
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(node); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// ReadProtobufFromYAML reads all of r and parses it as a YAML protobuf message,
+// field names are accepted in the same forms as ReadProtobufFromJSON
+func ReadProtobufFromYAML(message proto.Message, r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	node := &yaml.Node{}
+	if err = yaml.Unmarshal(data, node); err != nil {
+		return err
+	}
+
+	buffer := &bytes.Buffer{}
+	if err = yamlNodeToJSON(buffer, node); err != nil {
+		return err
+	}
+	return JSONToProtobufWithOptions(buffer.Bytes(), message, DefaultJSONOptions())
+}
+
+// jsonToYAMLNode converts the next JSON value of decoder into a YAML node, keeping the field order
+func jsonToYAMLNode(decoder *json.Decoder) (*yaml.Node, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch value := token.(type) {
+	case json.Delim:
+		if value == '{' {
+			node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			for decoder.More() {
+				key, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				child, err := jsonToYAMLNode(decoder)
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content,
+					&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key.(string)}, child)
+			}
+			_, err = decoder.Token() // '}'
+			return node, err
+		}
+
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for decoder.More() {
+			child, err := jsonToYAMLNode(decoder)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, child)
+		}
+		_, err = decoder.Token() // ']'
+		return node, err
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}, nil
+	case json.Number:
+		tag := "!!float"
+		if _, err := value.Int64(); err == nil {
+			tag = "!!int"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value.String()}, nil
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(value)}, nil
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	default:
+		return nil, fmt.Errorf("unexpected JSON token %v", token)
+	}
+}
+
+// yamlNodeToJSON writes node as JSON to buffer
+func yamlNodeToJSON(buffer *bytes.Buffer, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			buffer.WriteString("{}")
+			return nil
+		}
+		return yamlNodeToJSON(buffer, node.Content[0])
+	case yaml.AliasNode:
+		return yamlNodeToJSON(buffer, node.Alias)
+	case yaml.MappingNode:
+		buffer.WriteByte('{')
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if key.Kind != yaml.ScalarNode {
+				return fmt.Errorf("line %d: mapping keys must be scalars", key.Line)
+			}
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			writeJSONString(buffer, key.Value)
+			buffer.WriteByte(':')
+			if err := yamlNodeToJSON(buffer, node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte('}')
+		return nil
+	case yaml.SequenceNode:
+		buffer.WriteByte('[')
+		for i, child := range node.Content {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			if err := yamlNodeToJSON(buffer, child); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte(']')
+		return nil
+	case yaml.ScalarNode:
+		return yamlScalarToJSON(buffer, node)
+	default:
+		return fmt.Errorf("line %d: unsupported YAML node", node.Line)
+	}
+}
+
+func yamlScalarToJSON(buffer *bytes.Buffer, node *yaml.Node) error {
+	switch node.ShortTag() {
+	case "!!null":
+		buffer.WriteString("null")
+	case "!!bool":
+		var value bool
+		if err := node.Decode(&value); err != nil {
+			return err
+		}
+		buffer.WriteString(strconv.FormatBool(value))
+	case "!!int":
+		var value json.Number
+		var i int64
+		if err := node.Decode(&i); err == nil {
+			value = json.Number(strconv.FormatInt(i, 10))
+		} else {
+			var u uint64
+			if err := node.Decode(&u); err != nil {
+				return err
+			}
+			value = json.Number(strconv.FormatUint(u, 10))
+		}
+		buffer.WriteString(value.String())
+	case "!!float":
+		var value float64
+		if err := node.Decode(&value); err != nil {
+			return err
+		}
+		// protojson 用字符串表示非有限浮点数
+		switch {
+		case math.IsNaN(value):
+			buffer.WriteString(`"NaN"`)
+		case math.IsInf(value, 1):
+			buffer.WriteString(`"Infinity"`)
+		case math.IsInf(value, -1):
+			buffer.WriteString(`"-Infinity"`)
+		default:
+			buffer.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+		}
+	default:
+		writeJSONString(buffer, node.Value)
+	}
+	return nil
+}
+
+func writeJSONString(buffer *bytes.Buffer, s string) {
+	data, _ := json.Marshal(s)
+	buffer.Write(data)
+}
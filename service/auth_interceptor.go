@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+)
+
+// AuthInterceptor is a server interceptor for authentication and authorization
+type AuthInterceptor struct {
+	jwtManager      *JWTManager
+	accessibleRoles map[string][]string
+	publicMethods   map[string]bool
+}
+
+// NewAuthInterceptor returns a new auth interceptor. accessibleRoles maps the full
+// method names to the roles allowed to call them, and publicMethods lists the methods
+// anyone can call without a token. Methods in neither of them are denied to everyone,
+// so a new RPC cannot be called until its roles are listed.
+func NewAuthInterceptor(
+	jwtManager *JWTManager,
+	accessibleRoles map[string][]string,
+	publicMethods map[string]bool,
+) *AuthInterceptor {
+	return &AuthInterceptor{jwtManager, accessibleRoles, publicMethods}
+}
+
+// PublicMethods returns the methods that can be called without a token:
+// login, health checking and server reflection
+func PublicMethods() map[string]bool {
+	return map[string]bool{
+		"/" + pb.AuthService_ServiceDesc.ServiceName + "/Login":                               true,
+		"/" + healthpb.Health_ServiceDesc.ServiceName + "/Check":                              true,
+		"/" + healthpb.Health_ServiceDesc.ServiceName + "/Watch":                              true,
+		"/" + reflectionpb.ServerReflection_ServiceDesc.ServiceName + "/ServerReflectionInfo": true,
+	}
+}
+
+// LaptopServiceRoles returns the roles allowed to call each laptop service RPC:
+// admins manage laptops, users can get, search and rate them
+func LaptopServiceRoles() map[string][]string {
+	const laptopServicePath = "/techschool.pcbook.LaptopService/"
+
+	return map[string][]string{
+		laptopServicePath + "CreateLaptop": {RoleAdmin},
+		laptopServicePath + "UpdateLaptop": {RoleAdmin},
+		laptopServicePath + "UploadImage":  {RoleAdmin},
+		laptopServicePath + "GetLaptop":    {RoleAdmin, RoleUser},
+		laptopServicePath + "SearchLaptop": {RoleAdmin, RoleUser},
+		laptopServicePath + "RateLaptop":   {RoleAdmin, RoleUser},
+	}
+}
+
+// Unary returns a server interceptor function to authenticate and authorize unary RPC
+func (interceptor *AuthInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		log.Println("--> unary interceptor: ", info.FullMethod)
+
+		err := interceptor.authorize(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// Stream returns a server interceptor function to authenticate and authorize stream RPC
+func (interceptor *AuthInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		log.Println("--> stream interceptor: ", info.FullMethod)
+
+		err := interceptor.authorize(stream.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, stream)
+	}
+}
+
+func (interceptor *AuthInterceptor) authorize(ctx context.Context, method string) error {
+	if interceptor.publicMethods[method] {
+		return nil
+	}
+
+	accessibleRoles, ok := interceptor.accessibleRoles[method]
+	if !ok {
+		// 没有列出角色的方法默认拒绝，避免新增的RPC无需令牌即可调用
+		return status.Errorf(codes.PermissionDenied, "method %s is not accessible", method)
+	}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return status.Errorf(codes.Unauthenticated, "metadata is not provided")
+	}
+
+	values := md["authorization"]
+	if len(values) == 0 {
+		return status.Errorf(codes.Unauthenticated, "authorization token is not provided")
+	}
+
+	accessToken, ok := bearerToken(values[0])
+	if !ok {
+		return status.Errorf(codes.Unauthenticated, "authorization must use the Bearer scheme")
+	}
+
+	claims, err := interceptor.jwtManager.Verify(accessToken)
+	if err != nil {
+		return status.Errorf(codes.Unauthenticated, "access token is invalid: %v", err)
+	}
+
+	for _, role := range accessibleRoles {
+		if role == claims.Role {
+			return nil
+		}
+	}
+
+	return status.Errorf(codes.PermissionDenied, "no permission to access this RPC")
+}
+
+// bearerToken returns the token of a "Bearer <token>" authorization value
+func bearerToken(authorization string) (string, bool) {
+	const prefix = "bearer "
+	if len(authorization) <= len(prefix) || !strings.EqualFold(authorization[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(authorization[len(prefix):]), true
+}
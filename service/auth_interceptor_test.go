@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/Ruadgedy/pcbook/sample"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthInterceptor(t *testing.T) {
+	t.Parallel()
+
+	userStore := NewInMemoryUserStore()
+	err := SeedUsers(userStore, []UserConfig{
+		{Username: "admin1", Password: "secret", Role: RoleAdmin},
+		{Username: "user1", Password: "secret", Role: RoleUser},
+	})
+	require.NoError(t, err)
+
+	jwtManager := NewJWTManager("secret key", time.Minute)
+	authServer, err := NewAuthServer(userStore, jwtManager)
+	require.NoError(t, err)
+	laptopServer := NewLaptopServer(NewInMemoryLaptopStore(), nil, NewInMemoryRatingStore())
+
+	interceptor := NewAuthInterceptor(jwtManager, LaptopServiceRoles(), PublicMethods())
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(interceptor.Unary()),
+		grpc.StreamInterceptor(interceptor.Stream()),
+	)
+	pb.RegisterAuthServiceServer(grpcServer, authServer)
+	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	authClient := pb.NewAuthServiceClient(conn)
+	laptopClient := pb.NewLaptopServiceClient(conn)
+
+	// 登录不需要令牌
+	login := func(username string) string {
+		res, err := authClient.Login(context.Background(), &pb.LoginRequest{Username: username, Password: "secret"})
+		require.NoError(t, err)
+		return res.GetAccessToken()
+	}
+	adminToken := login("admin1")
+	userToken := login("user1")
+	otherKeyToken, err := NewJWTManager("other key", time.Minute).Generate(&User{Username: "admin1", Role: RoleAdmin})
+	require.NoError(t, err)
+
+	createLaptop := func(authorization string) error {
+		ctx := context.Background()
+		if authorization != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "authorization", authorization)
+		}
+		_, err := laptopClient.CreateLaptop(ctx, &pb.CreateLaptopRequest{Laptop: sample.NewLaptop()})
+		return err
+	}
+
+	testCases := []struct {
+		name          string
+		authorization string
+		code          codes.Code
+	}{
+		{
+			name:          "admin",
+			authorization: "Bearer " + adminToken,
+			code:          codes.OK,
+		},
+		{
+			name:          "lower_case_scheme",
+			authorization: "bearer " + adminToken,
+			code:          codes.OK,
+		},
+		{
+			name:          "user_permission_denied",
+			authorization: "Bearer " + userToken,
+			code:          codes.PermissionDenied,
+		},
+		{
+			name: "no_token",
+			code: codes.Unauthenticated,
+		},
+		{
+			name:          "no_bearer_scheme",
+			authorization: adminToken,
+			code:          codes.Unauthenticated,
+		},
+		{
+			name:          "invalid_signature",
+			authorization: "Bearer " + otherKeyToken,
+			code:          codes.Unauthenticated,
+		},
+	}
+
+	for _, tc := range testCases {
+		err := createLaptop(tc.authorization)
+		require.Equal(t, tc.code, status.Code(err), tc.name)
+	}
+
+	// 普通用户可以调用流式的搜索
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+userToken)
+	stream, err := laptopClient.SearchLaptop(ctx, &pb.SearchLaptopRequest{Filter: &pb.Filter{}})
+	require.NoError(t, err)
+	count := 0
+	for {
+		_, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		count++
+	}
+	require.Equal(t, 2, count)
+
+	stream, err = laptopClient.SearchLaptop(context.Background(), &pb.SearchLaptopRequest{Filter: &pb.Filter{}})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestAuthInterceptorDenyByDefault(t *testing.T) {
+	t.Parallel()
+
+	jwtManager := NewJWTManager("secret key", time.Minute)
+	adminToken, err := jwtManager.Generate(&User{Username: "admin1", Role: RoleAdmin})
+	require.NoError(t, err)
+
+	// 模拟忘记为 GetLaptop 列出角色
+	roles := LaptopServiceRoles()
+	delete(roles, "/techschool.pcbook.LaptopService/GetLaptop")
+	unary := NewAuthInterceptor(jwtManager, roles, PublicMethods()).Unary()
+
+	testCases := []struct {
+		name          string
+		method        string
+		authorization string
+		code          codes.Code
+	}{
+		{
+			name:          "unmapped_method_with_admin_token",
+			method:        "/techschool.pcbook.LaptopService/GetLaptop",
+			authorization: "Bearer " + adminToken,
+			code:          codes.PermissionDenied,
+		},
+		{
+			name:   "unmapped_method_without_token",
+			method: "/techschool.pcbook.LaptopService/DeleteLaptop",
+			code:   codes.PermissionDenied,
+		},
+		{
+			name:   "unknown_service",
+			method: "/techschool.pcbook.OtherService/Call",
+			code:   codes.PermissionDenied,
+		},
+		{
+			name:   "public_login",
+			method: "/techschool.pcbook.AuthService/Login",
+			code:   codes.OK,
+		},
+		{
+			name:   "public_health_check",
+			method: "/grpc.health.v1.Health/Check",
+			code:   codes.OK,
+		},
+		{
+			name:          "mapped_method",
+			method:        "/techschool.pcbook.LaptopService/SearchLaptop",
+			authorization: "Bearer " + adminToken,
+			code:          codes.OK,
+		},
+	}
+
+	for _, tc := range testCases {
+		ctx := context.Background()
+		if tc.authorization != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", tc.authorization))
+		}
+
+		called := false
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			called = true
+			return nil, nil
+		}
+		_, err := unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tc.method}, handler)
+		require.Equal(t, tc.code, status.Code(err), tc.name)
+		require.Equal(t, tc.code == codes.OK, called, tc.name)
+	}
+}
+
+func TestLaptopServiceRolesCoverEveryMethod(t *testing.T) {
+	t.Parallel()
+
+	roles := LaptopServiceRoles()
+	path := "/" + pb.LaptopService_ServiceDesc.ServiceName + "/"
+	for _, method := range pb.LaptopService_ServiceDesc.Methods {
+		require.Contains(t, roles, path+method.MethodName)
+	}
+	for _, stream := range pb.LaptopService_ServiceDesc.Streams {
+		require.Contains(t, roles, path+stream.StreamName)
+	}
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AuthServer is the server for authentication
+type AuthServer struct {
+	pb.UnimplementedAuthServiceServer
+	userStore  UserStore
+	jwtManager *JWTManager
+	// dummyUser is checked against the password of unknown users, so the response
+	// time doesn't reveal whether a username exists
+	dummyUser *User
+}
+
+// NewAuthServer returns a new auth server
+func NewAuthServer(userStore UserStore, jwtManager *JWTManager) (*AuthServer, error) {
+	dummyUser, err := NewUser("", "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	server := &AuthServer{
+		userStore:  userStore,
+		jwtManager: jwtManager,
+		dummyUser:  dummyUser,
+	}
+	return server, nil
+}
+
+// CheckHealth returns an error if the user store of the server is not ready
+func (server *AuthServer) CheckHealth(ctx context.Context) error {
+	return checkHealth(ctx, namedComponent{"user store", server.userStore})
+}
+
+// Login is a unary RPC to login user
+func (server *AuthServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	log.Printf("receive a login request for user: %s", req.GetUsername())
+
+	user, err := server.userStore.Find(req.GetUsername())
+	if err != nil {
+		return nil, logError(status.Errorf(codes.Internal, "cannot find user: %v", err))
+	}
+
+	if user == nil {
+		server.dummyUser.IsCorrectPassword(req.GetPassword())
+		return nil, logError(status.Errorf(codes.Unauthenticated, "incorrect username/password"))
+	}
+	if !user.IsCorrectPassword(req.GetPassword()) {
+		return nil, logError(status.Errorf(codes.Unauthenticated, "incorrect username/password"))
+	}
+
+	token, err := server.jwtManager.Generate(user)
+	if err != nil {
+		return nil, logError(status.Errorf(codes.Internal, "cannot generate access token: %v", err))
+	}
+
+	res := &pb.LoginResponse{AccessToken: token}
+	return res, nil
+}
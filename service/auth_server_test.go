@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthServerLogin(t *testing.T) {
+	t.Parallel()
+
+	userStore := NewInMemoryUserStore()
+	user, err := NewUser("admin1", "secret", RoleAdmin)
+	require.NoError(t, err)
+	require.NoError(t, userStore.Save(user))
+
+	jwtManager := NewJWTManager("secret key", time.Minute)
+	server, err := NewAuthServer(userStore, jwtManager)
+	require.NoError(t, err)
+
+	res, err := server.Login(context.Background(), &pb.LoginRequest{Username: "admin1", Password: "secret"})
+	require.NoError(t, err)
+
+	claims, err := jwtManager.Verify(res.GetAccessToken())
+	require.NoError(t, err)
+	require.Equal(t, "admin1", claims.Username)
+	require.Equal(t, RoleAdmin, claims.Role)
+
+	testCases := []struct {
+		name string
+		req  *pb.LoginRequest
+	}{
+		{
+			name: "wrong_password",
+			req:  &pb.LoginRequest{Username: "admin1", Password: "wrong"},
+		},
+		{
+			name: "unknown_user",
+			req:  &pb.LoginRequest{Username: "user1", Password: "secret"},
+		},
+		{
+			name: "empty_request",
+			req:  &pb.LoginRequest{},
+		},
+	}
+
+	for _, tc := range testCases {
+		res, err := server.Login(context.Background(), tc.req)
+		require.Nil(t, res, tc.name)
+		require.Equal(t, codes.Unauthenticated, status.Code(err), tc.name)
+	}
+}
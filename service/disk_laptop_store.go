@@ -0,0 +1,474 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/Ruadgedy/pcbook/serializer"
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultCompactionThreshold is the number of obsolete log records that triggers a compaction
+const DefaultCompactionThreshold = 1000
+
+// maxLogRecordSize protects the replay from allocating huge buffers for corrupted lengths
+const maxLogRecordSize = serializer.MaxRecordSize
+
+// Operations of the log records
+const (
+	logOpSave   byte = 1
+	logOpDelete byte = 2
+)
+
+// logRecordHeaderSize is the size of the length and checksum of a log record
+const logRecordHeaderSize = 8
+
+var (
+	errCorruptLogRecord = errors.New("corrupt log record")
+	crcTable            = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// DiskLaptopStore stores laptop in an append-only log file, and keeps them in memory for reads.
+//
+// Each record of the log is a save with the laptop protobuf bytes, or a delete
+// with the laptop ID, prefixed by its length and CRC-32C checksum. When the
+// store is opened the log is replayed, and a torn or corrupted record at the
+// end, left by a crash in the middle of a write, is discarded. A corrupted record
+// in the middle of the log makes the open fail and leaves the log untouched.
+// The log is rewritten without obsolete records when they outnumber the stored laptops.
+type DiskLaptopStore struct {
+	mutex               sync.Mutex
+	filename            string
+	file                *os.File
+	size                int64
+	records             int
+	memory              *InMemoryLaptopStore
+	syncWrites          bool
+	compactionThreshold int
+}
+
+// DiskLaptopStoreOption configures a DiskLaptopStore
+type DiskLaptopStoreOption func(*DiskLaptopStore)
+
+// WithSyncWrites sets whether every write is synced to disk before it returns, it is enabled by default.
+// Without it, a crash of the machine may lose the latest writes, but never corrupts the log.
+func WithSyncWrites(sync bool) DiskLaptopStoreOption {
+	return func(store *DiskLaptopStore) {
+		store.syncWrites = sync
+	}
+}
+
+// WithCompactionThreshold sets the number of obsolete log records that triggers a compaction,
+// 0 disables automatic compaction
+func WithCompactionThreshold(threshold int) DiskLaptopStoreOption {
+	return func(store *DiskLaptopStore) {
+		store.compactionThreshold = threshold
+	}
+}
+
+// NewDiskLaptopStore opens the log file of a DiskLaptopStore, it is created if it doesn't exist
+func NewDiskLaptopStore(filename string, opts ...DiskLaptopStoreOption) (*DiskLaptopStore, error) {
+	store := &DiskLaptopStore{
+		filename:            filename,
+		memory:              NewInMemoryLaptopStore(),
+		syncWrites:          true,
+		compactionThreshold: DefaultCompactionThreshold,
+	}
+	for _, opt := range opts {
+		opt(store)
+	}
+
+	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open laptop log: %w", err)
+	}
+	store.file = file
+
+	err = store.replay()
+	if err == nil {
+		_, err = file.Seek(store.size, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("cannot read laptop log %s: %w", filename, err)
+	}
+	return store, nil
+}
+
+// replay reads every record of the log. A torn record at the end, left by a crash in
+// the middle of a write, is truncated. A corrupted record followed by other records
+// cannot be left by a crash, the replay fails instead of discarding the valid records.
+func (store *DiskLaptopStore) replay() error {
+	info, err := store.file.Stat()
+	if err != nil {
+		return err
+	}
+	fileSize := info.Size()
+
+	reader := bufio.NewReader(store.file)
+	for {
+		op, payload, size, err := readLogRecord(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err == nil {
+			err = store.apply(op, payload)
+		}
+		if err != nil {
+			if !errors.Is(err, serializer.ErrTruncatedRecord) && !errors.Is(err, errCorruptLogRecord) {
+				return err
+			}
+
+			torn, tornErr := store.isTornTail(size, fileSize, err)
+			if tornErr != nil {
+				return tornErr
+			}
+			if !torn {
+				return fmt.Errorf("%w at offset %d, followed by %d bytes of records",
+					err, store.size, fileSize-store.size-size)
+			}
+
+			log.Printf("discard torn laptop log %s after offset %d: %v", store.filename, store.size, err)
+			if err = store.file.Truncate(store.size); err != nil {
+				return err
+			}
+			return store.file.Sync()
+		}
+
+		store.size += size
+		store.records++
+	}
+}
+
+// isTornTail reports whether the record at store.size that failed with err runs to the end of the log.
+// size is the size of the record, or 0 if its header is invalid.
+func (store *DiskLaptopStore) isTornTail(size int64, fileSize int64, err error) (bool, error) {
+	if errors.Is(err, serializer.ErrTruncatedRecord) {
+		return true, nil
+	}
+	if size > 0 {
+		return store.size+size == fileSize, nil
+	}
+
+	// 文件系统崩溃后，文件末尾可能是一段全零的数据，其中没有可读的记录头
+	tail := io.NewSectionReader(store.file, store.size, fileSize-store.size)
+	buffer := make([]byte, 32*1024)
+	for {
+		n, err := tail.Read(buffer)
+		for _, b := range buffer[:n] {
+			if b != 0 {
+				return false, nil
+			}
+		}
+		if err == io.EOF {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+// apply applies a log record to the laptops in memory
+func (store *DiskLaptopStore) apply(op byte, payload []byte) error {
+	switch op {
+	case logOpSave:
+		laptop := &pb.Laptop{}
+		if err := proto.Unmarshal(payload, laptop); err != nil {
+			return fmt.Errorf("%w: %v", errCorruptLogRecord, err)
+		}
+		// 删除后可以重新保存同一个ID，后写入的记录覆盖之前的
+		store.memory.Delete(laptop.Id)
+		return store.memory.Save(laptop)
+	case logOpDelete:
+		store.memory.Delete(string(payload))
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown operation %d", errCorruptLogRecord, op)
+	}
+}
+
+// Save saves the laptop to the store
+func (store *DiskLaptopStore) Save(laptop *pb.Laptop) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.file == nil {
+		return os.ErrClosed
+	}
+
+	found, err := store.memory.Find(laptop.Id)
+	if err != nil {
+		return err
+	}
+	if found != nil {
+		return ErrAlreadyExists
+	}
+
+	payload, err := proto.Marshal(laptop)
+	if err != nil {
+		return fmt.Errorf("cannot marshal laptop: %w", err)
+	}
+
+	if err = store.append(logOpSave, payload); err != nil {
+		return err
+	}
+	if err = store.memory.Save(laptop); err != nil {
+		return err
+	}
+	return store.maybeCompact()
+}
+
+// Find finds a laptop by ID
+func (store *DiskLaptopStore) Find(id string) (*pb.Laptop, error) {
+	return store.memory.Find(id)
+}
+
+// Delete deletes a laptop by ID
+func (store *DiskLaptopStore) Delete(id string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.file == nil {
+		return os.ErrClosed
+	}
+
+	found, err := store.memory.Find(id)
+	if err != nil {
+		return err
+	}
+	if found == nil {
+		return ErrNotFound
+	}
+
+	if err = store.append(logOpDelete, []byte(id)); err != nil {
+		return err
+	}
+	if err = store.memory.Delete(id); err != nil {
+		return err
+	}
+	return store.maybeCompact()
+}
+
+// Update updates a laptop by ID, the updated laptop is appended to the log
+func (store *DiskLaptopStore) Update(id string, update func(laptop *pb.Laptop) error) (*pb.Laptop, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.file == nil {
+		return nil, os.ErrClosed
+	}
+
+	laptop, err := store.memory.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	if laptop == nil {
+		return nil, ErrNotFound
+	}
+
+	updated, err := updateCopy(laptop, update)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := proto.Marshal(updated)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal laptop: %w", err)
+	}
+	if err = store.append(logOpSave, payload); err != nil {
+		return nil, err
+	}
+
+	// 保存记录会覆盖同一个ID之前的记录，重放时得到相同的结果
+	updated, err = store.memory.Update(id, func(laptop *pb.Laptop) error {
+		proto.Reset(laptop)
+		proto.Merge(laptop, updated)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, store.maybeCompact()
+}
+
+// Search searches for laptops with filter
+func (store *DiskLaptopStore) Search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) error {
+	return store.memory.Search(ctx, filter, found)
+}
+
+// Compact rewrites the log with one record per stored laptop.
+// The new log atomically replaces the old one, which is kept if the compaction fails.
+func (store *DiskLaptopStore) Compact() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.file == nil {
+		return os.ErrClosed
+	}
+	return store.compact()
+}
+
+// CheckHealth returns an error if the log file is closed or cannot be accessed
+func (store *DiskLaptopStore) CheckHealth(ctx context.Context) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.file == nil {
+		return os.ErrClosed
+	}
+	_, err := store.file.Stat()
+	return err
+}
+
+// Close closes the log file
+func (store *DiskLaptopStore) Close() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.file == nil {
+		return os.ErrClosed
+	}
+
+	err := store.file.Close()
+	store.file = nil
+	return err
+}
+
+// append writes a record at the end of the log
+func (store *DiskLaptopStore) append(op byte, payload []byte) error {
+	record := appendLogRecord(nil, op, payload)
+
+	_, err := store.file.Write(record)
+	if err == nil && store.syncWrites {
+		err = store.file.Sync()
+	}
+	if err != nil {
+		// 尽量去掉写了一半的记录，失败也没关系，下次打开时会被丢弃
+		store.file.Truncate(store.size)
+		store.file.Seek(store.size, io.SeekStart)
+		return fmt.Errorf("cannot write laptop log: %w", err)
+	}
+
+	store.size += int64(len(record))
+	store.records++
+	return nil
+}
+
+func (store *DiskLaptopStore) maybeCompact() error {
+	live := len(store.memory.data)
+	obsolete := store.records - live
+	if store.compactionThreshold <= 0 || obsolete < store.compactionThreshold || obsolete <= live {
+		return nil
+	}
+
+	if err := store.compact(); err != nil {
+		// 写入已经成功，压缩失败只影响日志大小
+		log.Printf("cannot compact laptop log %s: %v", store.filename, err)
+	}
+	return nil
+}
+
+func (store *DiskLaptopStore) compact() error {
+	var laptops []*pb.Laptop
+	err := store.memory.Search(context.Background(), &pb.Filter{}, func(laptop *pb.Laptop) error {
+		laptops = append(laptops, laptop)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = serializer.WriteFileAtomic(store.filename, serializer.FileOptions{}, func(w io.Writer) error {
+		writer := bufio.NewWriter(w)
+		var record []byte
+		for _, laptop := range laptops {
+			payload, err := proto.Marshal(laptop)
+			if err != nil {
+				return fmt.Errorf("cannot marshal laptop: %w", err)
+			}
+			record = appendLogRecord(record[:0], logOpSave, payload)
+			if _, err = writer.Write(record); err != nil {
+				return err
+			}
+		}
+		return writer.Flush()
+	})
+	if err != nil {
+		return fmt.Errorf("cannot compact laptop log: %w", err)
+	}
+
+	// 旧文件已经被替换，重新打开新的日志文件
+	file, err := os.OpenFile(store.filename, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("cannot open compacted laptop log: %w", err)
+	}
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("cannot open compacted laptop log: %w", err)
+	}
+
+	store.file.Close()
+	store.file = file
+	store.size = size
+	store.records = len(laptops)
+	return nil
+}
+
+// appendLogRecord appends the encoded record to buffer
+func appendLogRecord(buffer []byte, op byte, payload []byte) []byte {
+	var header [logRecordHeaderSize]byte
+	crc := crc32.Update(crc32.Update(0, crcTable, []byte{op}), crcTable, payload)
+	binary.BigEndian.PutUint32(header[:4], uint32(1+len(payload)))
+	binary.BigEndian.PutUint32(header[4:], crc)
+
+	buffer = append(buffer, header[:]...)
+	buffer = append(buffer, op)
+	return append(buffer, payload...)
+}
+
+// readLogRecord reads one record, it returns io.EOF at the end of the log.
+// The returned size is the size of the record in the log, including when its
+// checksum doesn't match, and 0 if the header is incomplete or invalid.
+func readLogRecord(reader io.Reader) (byte, []byte, int64, error) {
+	var header [logRecordHeaderSize]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, 0, serializer.ErrTruncatedRecord
+		}
+		return 0, nil, 0, err
+	}
+
+	size := binary.BigEndian.Uint32(header[:4])
+	if size == 0 || size > maxLogRecordSize {
+		return 0, nil, 0, fmt.Errorf("%w: invalid size %d", errCorruptLogRecord, size)
+	}
+	recordSize := int64(logRecordHeaderSize) + int64(size)
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return 0, nil, recordSize, serializer.ErrTruncatedRecord
+		}
+		return 0, nil, recordSize, err
+	}
+
+	if crc32.Checksum(data, crcTable) != binary.BigEndian.Uint32(header[4:]) {
+		return 0, nil, recordSize, fmt.Errorf("%w: checksum mismatch", errCorruptLogRecord)
+	}
+	return data[0], data[1:], recordSize, nil
+}
@@ -0,0 +1,364 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/Ruadgedy/pcbook/sample"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestDiskLaptopStoreReopen(t *testing.T) {
+	t.Parallel()
+
+	filename := filepath.Join(t.TempDir(), "laptops.log")
+	store, err := NewDiskLaptopStore(filename)
+	require.NoError(t, err)
+
+	laptops := []*pb.Laptop{sample.NewLaptop(), sample.NewLaptop(), sample.NewLaptop()}
+	for _, laptop := range laptops {
+		require.NoError(t, store.Save(laptop))
+	}
+	require.ErrorIs(t, store.Save(laptops[0]), ErrAlreadyExists)
+
+	require.NoError(t, store.Delete(laptops[1].Id))
+	require.ErrorIs(t, store.Delete(laptops[1].Id), ErrNotFound)
+	require.NoError(t, store.Close())
+
+	store, err = NewDiskLaptopStore(filename)
+	require.NoError(t, err)
+	defer store.Close()
+
+	for i, laptop := range laptops {
+		found, err := store.Find(laptop.Id)
+		require.NoError(t, err)
+		if i == 1 {
+			require.Nil(t, found)
+		} else {
+			require.True(t, proto.Equal(laptop, found))
+		}
+	}
+
+	// 删除后可以重新保存同一个ID
+	require.NoError(t, store.Save(laptops[1]))
+	found := 0
+	err = store.Search(context.Background(), &pb.Filter{}, func(laptop *pb.Laptop) error {
+		found++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, found)
+}
+
+func TestDiskLaptopStoreUpdate(t *testing.T) {
+	t.Parallel()
+
+	filename := filepath.Join(t.TempDir(), "laptops.log")
+	store, err := NewDiskLaptopStore(filename)
+	require.NoError(t, err)
+
+	laptop := sample.NewLaptop()
+	require.NoError(t, store.Save(laptop))
+
+	updated, err := store.Update(laptop.Id, func(laptop *pb.Laptop) error {
+		laptop.PriceUsd = 999
+		laptop.Version++
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	store, err = NewDiskLaptopStore(filename)
+	require.NoError(t, err)
+	defer store.Close()
+
+	found, err := store.Find(laptop.Id)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(updated, found))
+}
+
+func TestDiskLaptopStoreTornWrite(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "laptops.log")
+	store, err := NewDiskLaptopStore(filename)
+	require.NoError(t, err)
+
+	saved := []*pb.Laptop{sample.NewLaptop(), sample.NewLaptop()}
+	for _, laptop := range saved {
+		require.NoError(t, store.Save(laptop))
+	}
+	info, err := os.Stat(filename)
+	require.NoError(t, err)
+	valid := info.Size()
+
+	torn := sample.NewLaptop()
+	require.NoError(t, store.Save(torn))
+	require.NoError(t, store.Close())
+
+	data, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+
+	// 模拟在写入最后一条记录的任意位置崩溃
+	for size := valid; size < int64(len(data)); size++ {
+		crashed := filepath.Join(dir, "crashed.log")
+		require.NoError(t, ioutil.WriteFile(crashed, data[:size], 0644))
+
+		store, err := NewDiskLaptopStore(crashed)
+		require.NoError(t, err, "size %d", size)
+
+		for _, laptop := range saved {
+			found, err := store.Find(laptop.Id)
+			require.NoError(t, err)
+			require.True(t, proto.Equal(laptop, found), "size %d", size)
+		}
+		found, err := store.Find(torn.Id)
+		require.NoError(t, err)
+		require.Nil(t, found, "size %d", size)
+
+		// 不完整的记录被截掉之后，新的写入在重新打开后仍然可读
+		require.NoError(t, store.Save(torn))
+		require.NoError(t, store.Close())
+
+		store, err = NewDiskLaptopStore(crashed)
+		require.NoError(t, err)
+		found, err = store.Find(torn.Id)
+		require.NoError(t, err)
+		require.True(t, proto.Equal(torn, found), "size %d", size)
+		require.NoError(t, store.Close())
+	}
+}
+
+func TestDiskLaptopStoreCorruptRecord(t *testing.T) {
+	t.Parallel()
+
+	filename := filepath.Join(t.TempDir(), "laptops.log")
+	store, err := NewDiskLaptopStore(filename)
+	require.NoError(t, err)
+
+	kept := sample.NewLaptop()
+	require.NoError(t, store.Save(kept))
+	corrupted := sample.NewLaptop()
+	require.NoError(t, store.Save(corrupted))
+	require.NoError(t, store.Close())
+
+	data, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	data[len(data)-1] ^= 0xff
+	require.NoError(t, ioutil.WriteFile(filename, data, 0644))
+
+	store, err = NewDiskLaptopStore(filename)
+	require.NoError(t, err)
+	defer store.Close()
+
+	found, err := store.Find(kept.Id)
+	require.NoError(t, err)
+	require.NotNil(t, found)
+
+	found, err = store.Find(corrupted.Id)
+	require.NoError(t, err)
+	require.Nil(t, found)
+}
+
+func TestDiskLaptopStoreCorruptMiddleRecord(t *testing.T) {
+	t.Parallel()
+
+	filename := filepath.Join(t.TempDir(), "laptops.log")
+	store, err := NewDiskLaptopStore(filename)
+	require.NoError(t, err)
+
+	saved := []*pb.Laptop{}
+	for i := 0; i < 10; i++ {
+		laptop := sample.NewLaptop()
+		require.NoError(t, store.Save(laptop))
+		saved = append(saved, laptop)
+	}
+	require.NoError(t, store.Close())
+
+	data, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		corrupt func(data []byte)
+		message string
+	}{
+		{
+			// 第一条记录的数据
+			name:    "payload",
+			corrupt: func(data []byte) { data[logRecordHeaderSize+10] ^= 0xff },
+			message: "checksum mismatch",
+		},
+		{
+			// 第二条记录的长度
+			name: "size",
+			corrupt: func(data []byte) {
+				offset := logRecordHeaderSize + 1 + len(mustMarshal(t, saved[0]))
+				data[offset] ^= 0xff
+			},
+			message: "invalid size",
+		},
+	}
+
+	for _, tc := range testCases {
+		corrupted := append([]byte{}, data...)
+		tc.corrupt(corrupted)
+		require.NoError(t, ioutil.WriteFile(filename, corrupted, 0644))
+
+		_, err = NewDiskLaptopStore(filename)
+		require.ErrorIs(t, err, errCorruptLogRecord, tc.name)
+		require.Contains(t, err.Error(), tc.message, tc.name)
+
+		// 日志保持原样，修复损坏的字节之后所有记录都还在
+		unchanged, err := ioutil.ReadFile(filename)
+		require.NoError(t, err)
+		require.Equal(t, corrupted, unchanged, tc.name)
+	}
+
+	require.NoError(t, ioutil.WriteFile(filename, data, 0644))
+	store, err = NewDiskLaptopStore(filename)
+	require.NoError(t, err)
+	defer store.Close()
+	for _, laptop := range saved {
+		found, err := store.Find(laptop.Id)
+		require.NoError(t, err)
+		require.True(t, proto.Equal(laptop, found))
+	}
+}
+
+func TestDiskLaptopStoreZeroFilledTail(t *testing.T) {
+	t.Parallel()
+
+	filename := filepath.Join(t.TempDir(), "laptops.log")
+	store, err := NewDiskLaptopStore(filename)
+	require.NoError(t, err)
+	laptop := sample.NewLaptop()
+	require.NoError(t, store.Save(laptop))
+	require.NoError(t, store.Close())
+
+	info, err := os.Stat(filename)
+	require.NoError(t, err)
+	valid := info.Size()
+
+	// 文件系统崩溃后文件末尾可能被填充为零
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = file.Write(make([]byte, 100))
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	store, err = NewDiskLaptopStore(filename)
+	require.NoError(t, err)
+	defer store.Close()
+
+	found, err := store.Find(laptop.Id)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(laptop, found))
+
+	info, err = os.Stat(filename)
+	require.NoError(t, err)
+	require.Equal(t, valid, info.Size())
+}
+
+func mustMarshal(t *testing.T, laptop *pb.Laptop) []byte {
+	data, err := proto.Marshal(laptop)
+	require.NoError(t, err)
+	return data
+}
+
+func TestDiskLaptopStoreCompaction(t *testing.T) {
+	t.Parallel()
+
+	filename := filepath.Join(t.TempDir(), "laptops.log")
+	store, err := NewDiskLaptopStore(filename, WithCompactionThreshold(10), WithSyncWrites(false))
+	require.NoError(t, err)
+
+	kept := sample.NewLaptop()
+	require.NoError(t, store.Save(kept))
+	for i := 0; i < 100; i++ {
+		laptop := sample.NewLaptop()
+		require.NoError(t, store.Save(laptop))
+		require.NoError(t, store.Delete(laptop.Id))
+	}
+
+	// 200 条过期记录会触发多次压缩，日志中最多剩下阈值附近数量的过期记录
+	require.LessOrEqual(t, store.records, 1+10*2)
+
+	require.NoError(t, store.Compact())
+	require.Equal(t, 1, store.records)
+	require.NoError(t, store.Close())
+
+	store, err = NewDiskLaptopStore(filename)
+	require.NoError(t, err)
+	defer store.Close()
+
+	found, err := store.Find(kept.Id)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(kept, found))
+	require.Equal(t, 1, store.records)
+}
+
+// TestDiskLaptopStoreKilledWriter kills a process that saves laptops in a loop,
+// every laptop the process reported as saved must be found after a restart
+func TestDiskLaptopStoreKilledWriter(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test that starts a subprocess in short mode")
+	}
+	t.Parallel()
+
+	filename := filepath.Join(t.TempDir(), "laptops.log")
+	cmd := exec.Command(os.Args[0], "-test.run=^TestDiskLaptopStoreWriterProcess$")
+	cmd.Env = append(os.Environ(), "PCBOOK_LAPTOP_LOG="+filename)
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+
+	var saved []string
+	scanner := bufio.NewScanner(stdout)
+	for len(saved) < 200 && scanner.Scan() {
+		saved = append(saved, scanner.Text())
+	}
+	require.NoError(t, cmd.Process.Kill())
+	cmd.Wait()
+	require.Len(t, saved, 200)
+
+	store, err := NewDiskLaptopStore(filename)
+	require.NoError(t, err)
+	defer store.Close()
+
+	for _, id := range saved {
+		found, err := store.Find(id)
+		require.NoError(t, err)
+		require.NotNil(t, found, "laptop %s was saved before the crash", id)
+	}
+}
+
+// TestDiskLaptopStoreWriterProcess is the process killed by TestDiskLaptopStoreKilledWriter
+func TestDiskLaptopStoreWriterProcess(t *testing.T) {
+	filename := os.Getenv("PCBOOK_LAPTOP_LOG")
+	if filename == "" {
+		t.Skip("only run as a subprocess of TestDiskLaptopStoreKilledWriter")
+	}
+
+	store, err := NewDiskLaptopStore(filename, WithCompactionThreshold(50))
+	require.NoError(t, err)
+
+	for {
+		laptop := sample.NewLaptop()
+		require.NoError(t, store.Save(laptop))
+		os.Stdout.WriteString(laptop.Id + "\n")
+
+		// 不断保存再删除其他笔记本，让进程也可能在压缩过程中被杀死
+		other := sample.NewLaptop()
+		require.NoError(t, store.Save(other))
+		require.NoError(t, store.Delete(other.Id))
+	}
+}
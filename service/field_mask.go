@@ -0,0 +1,65 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// applyFieldMask copies the fields listed in paths from src to dst, a field that is not set in src
+// is cleared in dst. Paths are dot-separated proto field names, such as "cpu.number_cores";
+// repeated fields can only be replaced as a whole. dst may share memory with src afterwards.
+func applyFieldMask(dst, src proto.Message, paths []string) error {
+	for _, path := range paths {
+		err := applyFieldPath(dst.ProtoReflect(), src.ProtoReflect(), strings.Split(path, "."))
+		if err != nil {
+			return fmt.Errorf("invalid field path %q: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func applyFieldPath(dst, src protoreflect.Message, names []string) error {
+	field := dst.Descriptor().Fields().ByName(protoreflect.Name(names[0]))
+	if field == nil {
+		return fmt.Errorf("%s has no field %s", dst.Descriptor().FullName(), names[0])
+	}
+
+	if len(names) == 1 {
+		if src.Has(field) {
+			dst.Set(field, src.Get(field))
+		} else {
+			dst.Clear(field)
+		}
+		return nil
+	}
+
+	if field.Message() == nil || field.IsList() || field.IsMap() {
+		return fmt.Errorf("%s is not a message field", field.FullName())
+	}
+
+	// 两边都没有这个子消息时不需要修改，但仍然要检查剩余的路径
+	if !src.Has(field) && !dst.Has(field) {
+		return checkFieldPath(field.Message(), names[1:])
+	}
+	return applyFieldPath(dst.Mutable(field).Message(), src.Get(field).Message(), names[1:])
+}
+
+func checkFieldPath(message protoreflect.MessageDescriptor, names []string) error {
+	for i, name := range names {
+		field := message.Fields().ByName(protoreflect.Name(name))
+		if field == nil {
+			return fmt.Errorf("%s has no field %s", message.FullName(), name)
+		}
+		if i == len(names)-1 {
+			return nil
+		}
+		if field.Message() == nil || field.IsList() || field.IsMap() {
+			return fmt.Errorf("%s is not a message field", field.FullName())
+		}
+		message = field.Message()
+	}
+	return nil
+}
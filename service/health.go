@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// HealthChecker is implemented by the stores and servers that can report whether they are ready
+type HealthChecker interface {
+	// CheckHealth returns an error if requests cannot be served
+	CheckHealth(ctx context.Context) error
+}
+
+// namedComponent is a component of a server checked by checkHealth
+type namedComponent struct {
+	name      string
+	component interface{}
+}
+
+// checkHealth checks the components that implement HealthChecker, the others are always ready
+func checkHealth(ctx context.Context, components ...namedComponent) error {
+	for _, c := range components {
+		checker, ok := c.component.(HealthChecker)
+		if !ok {
+			continue
+		}
+		if err := checker.CheckHealth(ctx); err != nil {
+			return fmt.Errorf("%s is not ready: %w", c.name, err)
+		}
+	}
+	return nil
+}
+
+// HealthReporter sets the serving status of services in a gRPC health server from the
+// health of their checkers. The overall status, of the empty service name, is SERVING
+// only if every registered service is.
+type HealthReporter struct {
+	mutex    sync.Mutex
+	server   *health.Server
+	services []string
+	checkers map[string]HealthChecker
+	errors   map[string]error
+}
+
+// NewHealthReporter returns a new HealthReporter that updates server
+func NewHealthReporter(server *health.Server) *HealthReporter {
+	return &HealthReporter{
+		server:   server,
+		checkers: make(map[string]HealthChecker),
+		errors:   make(map[string]error),
+	}
+}
+
+// Register adds a service checked by checker, it is NOT_SERVING until the next check
+func (reporter *HealthReporter) Register(service string, checker HealthChecker) {
+	reporter.mutex.Lock()
+	defer reporter.mutex.Unlock()
+
+	if _, ok := reporter.checkers[service]; !ok {
+		reporter.services = append(reporter.services, service)
+	}
+	reporter.checkers[service] = checker
+	reporter.server.SetServingStatus(service, healthpb.HealthCheckResponse_NOT_SERVING)
+	reporter.server.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+}
+
+// Check checks every registered service once and updates their status
+func (reporter *HealthReporter) Check(ctx context.Context) {
+	reporter.mutex.Lock()
+	defer reporter.mutex.Unlock()
+
+	overall := healthpb.HealthCheckResponse_SERVING
+	for _, service := range reporter.services {
+		err := reporter.checkers[service].CheckHealth(ctx)
+
+		servingStatus := healthpb.HealthCheckResponse_SERVING
+		if err != nil {
+			servingStatus = healthpb.HealthCheckResponse_NOT_SERVING
+			overall = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+
+		// 只在状态变化时记录日志
+		previous, checked := reporter.errors[service]
+		if !checked || (previous == nil) != (err == nil) {
+			if err != nil {
+				log.Printf("service %s is not serving: %v", service, err)
+			} else {
+				log.Printf("service %s is serving", service)
+			}
+		}
+		reporter.errors[service] = err
+
+		reporter.server.SetServingStatus(service, servingStatus)
+	}
+	reporter.server.SetServingStatus("", overall)
+}
+
+// Run checks the services every interval until ctx is done, each check is limited to interval
+func (reporter *HealthReporter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, interval)
+		reporter.Check(checkCtx)
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
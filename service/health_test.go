@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+// fakeHealthChecker returns the error it is set to
+type fakeHealthChecker struct {
+	mutex sync.Mutex
+	err   error
+}
+
+func (checker *fakeHealthChecker) set(err error) {
+	checker.mutex.Lock()
+	defer checker.mutex.Unlock()
+	checker.err = err
+}
+
+func (checker *fakeHealthChecker) CheckHealth(ctx context.Context) error {
+	checker.mutex.Lock()
+	defer checker.mutex.Unlock()
+	return checker.err
+}
+
+func requireServingStatus(t *testing.T, server *health.Server, service string, expected healthpb.HealthCheckResponse_ServingStatus) {
+	res, err := server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	require.NoError(t, err)
+	require.Equal(t, expected, res.Status, "service %q", service)
+}
+
+func TestHealthReporter(t *testing.T) {
+	t.Parallel()
+
+	server := health.NewServer()
+	reporter := NewHealthReporter(server)
+
+	laptopChecker := &fakeHealthChecker{}
+	authChecker := &fakeHealthChecker{}
+	reporter.Register("laptop", laptopChecker)
+	reporter.Register("auth", authChecker)
+
+	// 检查之前服务都不可用
+	requireServingStatus(t, server, "laptop", healthpb.HealthCheckResponse_NOT_SERVING)
+	requireServingStatus(t, server, "", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	reporter.Check(context.Background())
+	requireServingStatus(t, server, "laptop", healthpb.HealthCheckResponse_SERVING)
+	requireServingStatus(t, server, "auth", healthpb.HealthCheckResponse_SERVING)
+	requireServingStatus(t, server, "", healthpb.HealthCheckResponse_SERVING)
+
+	laptopChecker.set(errors.New("disk is gone"))
+	reporter.Check(context.Background())
+	requireServingStatus(t, server, "laptop", healthpb.HealthCheckResponse_NOT_SERVING)
+	requireServingStatus(t, server, "auth", healthpb.HealthCheckResponse_SERVING)
+	requireServingStatus(t, server, "", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	laptopChecker.set(nil)
+	reporter.Check(context.Background())
+	requireServingStatus(t, server, "laptop", healthpb.HealthCheckResponse_SERVING)
+	requireServingStatus(t, server, "", healthpb.HealthCheckResponse_SERVING)
+}
+
+func TestHealthReporterRun(t *testing.T) {
+	t.Parallel()
+
+	server := health.NewServer()
+	reporter := NewHealthReporter(server)
+	checker := &fakeHealthChecker{}
+	reporter.Register("laptop", checker)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		reporter.Run(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	isServing := func() bool {
+		res, err := server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "laptop"})
+		return err == nil && res.Status == healthpb.HealthCheckResponse_SERVING
+	}
+	require.Eventually(t, isServing, time.Second, 5*time.Millisecond)
+
+	checker.set(errors.New("not ready"))
+	require.Eventually(t, func() bool { return !isServing() }, time.Second, 5*time.Millisecond)
+
+	cancel()
+	<-done
+}
+
+func TestLaptopServerCheckHealth(t *testing.T) {
+	t.Parallel()
+
+	// 内存中的存储始终可用
+	server := NewLaptopServer(NewInMemoryLaptopStore(), nil, NewInMemoryRatingStore())
+	require.NoError(t, server.CheckHealth(context.Background()))
+
+	imageFolder := t.TempDir()
+	server = NewLaptopServer(NewInMemoryLaptopStore(), NewDiskImageStore(imageFolder), nil)
+	require.NoError(t, server.CheckHealth(context.Background()))
+	require.NoError(t, os.Remove(imageFolder))
+	require.Error(t, server.CheckHealth(context.Background()))
+
+	diskStore, err := NewDiskLaptopStore(filepath.Join(t.TempDir(), "laptops.log"))
+	require.NoError(t, err)
+	server = NewLaptopServer(diskStore, nil, nil)
+	require.NoError(t, server.CheckHealth(context.Background()))
+	require.NoError(t, diskStore.Close())
+	require.ErrorIs(t, server.CheckHealth(context.Background()), os.ErrClosed)
+
+	db := openTestSQLDatabase(t)
+	sqlStore, err := NewSQLLaptopStore(db)
+	require.NoError(t, err)
+	server = NewLaptopServer(sqlStore, nil, nil)
+	require.NoError(t, server.CheckHealth(context.Background()))
+	require.NoError(t, db.Close())
+	require.Error(t, server.CheckHealth(context.Background()))
+}
+
+func TestHealthAndReflectionServices(t *testing.T) {
+	t.Parallel()
+
+	laptopServer := NewLaptopServer(NewInMemoryLaptopStore(), nil, nil)
+	healthServer := health.NewServer()
+	reporter := NewHealthReporter(healthServer)
+	reporter.Register(pb.LaptopService_ServiceDesc.ServiceName, laptopServer)
+	reporter.Check(context.Background())
+
+	grpcServer := grpc.NewServer()
+	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	reflection.Register(grpcServer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	res, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{
+		Service: pb.LaptopService_ServiceDesc.ServiceName,
+	})
+	require.NoError(t, err)
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, res.Status)
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	require.NoError(t, err)
+	err = stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	})
+	require.NoError(t, err)
+	reflectionRes, err := stream.Recv()
+	require.NoError(t, err)
+	require.NoError(t, stream.CloseSend())
+
+	services := []string{}
+	for _, service := range reflectionRes.GetListServicesResponse().GetService() {
+		services = append(services, service.Name)
+	}
+	require.ElementsMatch(t, []string{
+		pb.LaptopService_ServiceDesc.ServiceName,
+		"grpc.health.v1.Health",
+		"grpc.reflection.v1alpha.ServerReflection",
+	}, services)
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskImageStoreSave(t *testing.T) {
+	t.Parallel()
+
+	imageFolder := t.TempDir()
+	store := NewDiskImageStore(imageFolder)
+
+	data := []byte("not really a png")
+	imageID, err := store.Save("laptop-id", ".png", *bytes.NewBuffer(data))
+	require.NoError(t, err)
+	require.NotEmpty(t, imageID)
+
+	saved, err := ioutil.ReadFile(filepath.Join(imageFolder, imageID+".png"))
+	require.NoError(t, err)
+	require.Equal(t, data, saved)
+
+	_, err = NewDiskImageStore(filepath.Join(imageFolder, "missing")).Save("laptop-id", ".png", bytes.Buffer{})
+	require.Error(t, err)
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWTManager(t *testing.T) {
+	t.Parallel()
+
+	user := &User{Username: "admin1", Role: RoleAdmin}
+	manager := NewJWTManager("secret", time.Minute)
+
+	token, err := manager.Generate(user)
+	require.NoError(t, err)
+
+	claims, err := manager.Verify(token)
+	require.NoError(t, err)
+	require.Equal(t, "admin1", claims.Username)
+	require.Equal(t, RoleAdmin, claims.Role)
+	require.WithinDuration(t, time.Now().Add(time.Minute), claims.ExpiresAt.Time, 5*time.Second)
+
+	_, err = NewJWTManager("other secret", time.Minute).Verify(token)
+	require.Error(t, err)
+
+	_, err = manager.Verify(token + "x")
+	require.Error(t, err)
+
+	expired, err := NewJWTManager("secret", -time.Minute).Generate(user)
+	require.NoError(t, err)
+	_, err = manager.Verify(expired)
+	require.Error(t, err)
+}
+
+func TestJWTManagerRejectsOtherAlgorithms(t *testing.T) {
+	t.Parallel()
+
+	manager := NewJWTManager("secret", time.Minute)
+	claims := UserClaims{
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))},
+		Username:         "admin1",
+		Role:             RoleAdmin,
+	}
+
+	// 未签名的令牌不能通过验证
+	token, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+	_, err = manager.Verify(token)
+	require.Error(t, err)
+}
@@ -0,0 +1,373 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/Ruadgedy/pcbook/sample"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestClientCreateLaptop(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	serverAddress := startTestLaptopServer(t, laptopStore, nil, nil)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	laptop := sample.NewLaptop()
+	expectedID := laptop.Id
+	req := &pb.CreateLaptopRequest{
+		Laptop: laptop,
+	}
+
+	res, err := laptopClient.CreateLaptop(context.Background(), req)
+	require.NoError(t, err)
+	require.NotNil(t, res)
+	require.Equal(t, expectedID, res.Id)
+}
+
+func startTestLaptopServer(
+	t *testing.T,
+	laptopStore LaptopStore,
+	imageStore ImageStore,
+	ratingStore RatingStore,
+	opts ...LaptopServerOption,
+) string {
+	laptopServer := NewLaptopServer(laptopStore, imageStore, ratingStore, opts...)
+
+	grpcServer := grpc.NewServer()
+	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0") // 随机可用端口
+	require.NoError(t, err)
+
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	return listener.Addr().String()
+}
+
+func newTestLaptopClient(t *testing.T, serverAddress string) pb.LaptopServiceClient {
+	conn, err := grpc.Dial(serverAddress, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return pb.NewLaptopServiceClient(conn)
+}
+
+func TestClientSearchLaptop(t *testing.T) {
+	t.Parallel()
+
+	filter := &pb.Filter{
+		MaxPriceUsd: 2000,
+		MinCpuCores: 4,
+		MinCpuGhz:   2.2,
+		MinRam:      &pb.Memory{Value: 8, Unit: pb.Memory_GIGABYTE},
+	}
+
+	laptopStore := NewInMemoryLaptopStore()
+	expectedIDs := make(map[string]bool)
+
+	for i := 0; i < 6; i++ {
+		laptop := sample.NewLaptop()
+
+		switch i {
+		case 0:
+			laptop.PriceUsd = 2500
+		case 1:
+			laptop.Cpu.NumberCores = 2
+		case 2:
+			laptop.Cpu.MinGhz = 2.0
+		case 3:
+			laptop.Ram = &pb.Memory{Value: 4096, Unit: pb.Memory_MEGABYTE}
+		case 4:
+			laptop.PriceUsd = 1999
+			laptop.Cpu.NumberCores = 4
+			laptop.Cpu.MinGhz = 2.5
+			laptop.Ram = &pb.Memory{Value: 16, Unit: pb.Memory_GIGABYTE}
+			expectedIDs[laptop.Id] = true
+		case 5:
+			laptop.PriceUsd = 2000
+			laptop.Cpu.NumberCores = 6
+			laptop.Cpu.MinGhz = 2.8
+			laptop.Ram = &pb.Memory{Value: 64, Unit: pb.Memory_GIGABYTE}
+			expectedIDs[laptop.Id] = true
+		}
+
+		err := laptopStore.Save(laptop)
+		require.NoError(t, err)
+	}
+
+	serverAddress := startTestLaptopServer(t, laptopStore, nil, nil)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	req := &pb.SearchLaptopRequest{Filter: filter}
+	stream, err := laptopClient.SearchLaptop(context.Background(), req)
+	require.NoError(t, err)
+
+	found := 0
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		require.Contains(t, expectedIDs, res.GetLaptop().GetId())
+
+		found++
+	}
+
+	require.Equal(t, len(expectedIDs), found)
+}
+
+func TestClientSearchLaptopDeadline(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	for i := 0; i < 3; i++ {
+		err := laptopStore.Save(sample.NewLaptop())
+		require.NoError(t, err)
+	}
+
+	serverAddress := startTestLaptopServer(t, laptopStore, nil, nil)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+
+	stream, err := laptopClient.SearchLaptop(ctx, &pb.SearchLaptopRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	require.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
+
+func TestClientUploadImage(t *testing.T) {
+	t.Parallel()
+
+	testImageFolder := t.TempDir()
+
+	laptopStore := NewInMemoryLaptopStore()
+	imageStore := NewDiskImageStore(testImageFolder)
+
+	laptop := sample.NewLaptop()
+	err := laptopStore.Save(laptop)
+	require.NoError(t, err)
+
+	serverAddress := startTestLaptopServer(t, laptopStore, imageStore, nil)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	imageData := make([]byte, 3000)
+	_, err = rand.Read(imageData)
+	require.NoError(t, err)
+
+	res, err := uploadTestImage(laptopClient, laptop.GetId(), ".jpg", imageData, 1024)
+	require.NoError(t, err)
+	require.NotZero(t, res.GetId())
+	require.EqualValues(t, len(imageData), res.GetSize())
+
+	savedImagePath := filepath.Join(testImageFolder, res.GetId()+".jpg")
+	savedImage, err := ioutil.ReadFile(savedImagePath)
+	require.NoError(t, err)
+	require.Equal(t, imageData, savedImage)
+}
+
+func TestClientUploadImageFailure(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	imageStore := NewDiskImageStore(t.TempDir())
+
+	laptop := sample.NewLaptop()
+	err := laptopStore.Save(laptop)
+	require.NoError(t, err)
+
+	serverAddress := startTestLaptopServer(t, laptopStore, imageStore, nil, WithMaxImageSize(2048))
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	testCases := []struct {
+		name      string
+		laptopID  string
+		imageType string
+		size      int
+	}{
+		{"too_large", laptop.GetId(), ".jpg", 2049},
+		{"unknown_laptop", sample.NewLaptop().GetId(), ".jpg", 100},
+		{"invalid_image_type", laptop.GetId(), "/../../etc/passwd", 100},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := uploadTestImage(laptopClient, tc.laptopID, tc.imageType, make([]byte, tc.size), 1024)
+			require.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+	}
+}
+
+func uploadTestImage(
+	laptopClient pb.LaptopServiceClient,
+	laptopID string,
+	imageType string,
+	imageData []byte,
+	chunkSize int,
+) (*pb.UploadImageResponse, error) {
+	stream, err := laptopClient.UploadImage(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	req := &pb.UploadImageRequest{
+		Data: &pb.UploadImageRequest_Info{
+			Info: &pb.ImageInfo{
+				LaptopId:  laptopID,
+				ImageType: imageType,
+			},
+		},
+	}
+	if err = stream.Send(req); err != nil {
+		// 服务端已关闭流时，真正的错误通过 CloseAndRecv 返回
+		return stream.CloseAndRecv()
+	}
+
+	for len(imageData) > 0 {
+		n := chunkSize
+		if n > len(imageData) {
+			n = len(imageData)
+		}
+
+		req := &pb.UploadImageRequest{
+			Data: &pb.UploadImageRequest_ChunkData{
+				ChunkData: imageData[:n],
+			},
+		}
+		if err = stream.Send(req); err != nil {
+			return stream.CloseAndRecv()
+		}
+		imageData = imageData[n:]
+	}
+
+	return stream.CloseAndRecv()
+}
+
+func TestClientRateLaptop(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	ratingStore := NewInMemoryRatingStore()
+
+	laptop := sample.NewLaptop()
+	err := laptopStore.Save(laptop)
+	require.NoError(t, err)
+
+	serverAddress := startTestLaptopServer(t, laptopStore, nil, ratingStore)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	stream, err := laptopClient.RateLaptop(context.Background())
+	require.NoError(t, err)
+
+	scores := []float64{sample.RandomLaptopScore(), sample.RandomLaptopScore(), sample.RandomLaptopScore()}
+	sum := 0.0
+
+	for i, score := range scores {
+		req := &pb.RateLaptopRequest{
+			LaptopId: laptop.GetId(),
+			Score:    score,
+		}
+		err := stream.Send(req)
+		require.NoError(t, err)
+
+		res, err := stream.Recv()
+		require.NoError(t, err)
+
+		sum += score
+		require.Equal(t, laptop.GetId(), res.GetLaptopId())
+		require.EqualValues(t, i+1, res.GetRatedCount())
+		require.InDelta(t, sum/float64(i+1), res.GetAverageScore(), 1e-9)
+	}
+
+	err = stream.CloseSend()
+	require.NoError(t, err)
+
+	_, err = stream.Recv()
+	require.Equal(t, io.EOF, err)
+}
+
+func TestClientRateLaptopInvalidScore(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := NewInMemoryLaptopStore()
+	ratingStore := NewInMemoryRatingStore()
+
+	laptop := sample.NewLaptop()
+	err := laptopStore.Save(laptop)
+	require.NoError(t, err)
+
+	serverAddress := startTestLaptopServer(t, laptopStore, nil, ratingStore)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	testCases := []struct {
+		name  string
+		score float64
+	}{
+		{"nan", math.NaN()},
+		{"positive_infinity", math.Inf(1)},
+		{"negative_infinity", math.Inf(-1)},
+		{"too_low", MinLaptopScore - 0.5},
+		{"too_high", MaxLaptopScore + 0.5},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			stream, err := laptopClient.RateLaptop(context.Background())
+			require.NoError(t, err)
+
+			err = stream.Send(&pb.RateLaptopRequest{LaptopId: laptop.GetId(), Score: tc.score})
+			require.NoError(t, err)
+
+			_, err = stream.Recv()
+			require.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+	}
+
+	// 被拒绝的分数不会影响之后的平均分
+	rating, err := ratingStore.Add(laptop.GetId(), 5)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, rating.Count)
+	require.Equal(t, 5.0, rating.Average())
+}
+
+func TestClientRateLaptopNotFound(t *testing.T) {
+	t.Parallel()
+
+	serverAddress := startTestLaptopServer(t, NewInMemoryLaptopStore(), nil, NewInMemoryRatingStore())
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	stream, err := laptopClient.RateLaptop(context.Background())
+	require.NoError(t, err)
+
+	err = stream.Send(&pb.RateLaptopRequest{
+		LaptopId: sample.NewLaptop().GetId(),
+		Score:    sample.RandomLaptopScore(),
+	})
+	require.NoError(t, err)
+
+	_, err = stream.Recv()
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -0,0 +1,714 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/Ruadgedy/pcbook/memory"
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/google/btree"
+)
+
+// ErrInvalidCursor is returned when a query cursor is malformed or belongs to a query with another sort order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// LaptopField is a laptop field indexed by IndexedLaptopStore
+type LaptopField int
+
+const (
+	// FieldID is the laptop ID, it is the default sort order
+	FieldID LaptopField = iota
+	// FieldBrand is the laptop brand
+	FieldBrand
+	// FieldCPUCores is the number of CPU cores
+	FieldCPUCores
+	// FieldRAMBytes is the RAM size in bytes, using binary multipliers
+	FieldRAMBytes
+	// FieldPrice is the price in USD
+	FieldPrice
+	// FieldReleaseYear is the release year
+	FieldReleaseYear
+	// FieldScreenPanel is the screen panel
+	FieldScreenPanel
+	// FieldStorageDriver is the driver of the laptop storages. A laptop has one
+	// value per storage: it matches a driver if any of its storages does, and it
+	// is sorted by its smallest driver in ascending order and its largest one in
+	// descending order.
+	FieldStorageDriver
+)
+
+var laptopFields = []LaptopField{
+	FieldID,
+	FieldBrand,
+	FieldCPUCores,
+	FieldRAMBytes,
+	FieldPrice,
+	FieldReleaseYear,
+	FieldScreenPanel,
+	FieldStorageDriver,
+}
+
+func (field LaptopField) String() string {
+	switch field {
+	case FieldID:
+		return "id"
+	case FieldBrand:
+		return "brand"
+	case FieldCPUCores:
+		return "cpu_cores"
+	case FieldRAMBytes:
+		return "ram_bytes"
+	case FieldPrice:
+		return "price_usd"
+	case FieldReleaseYear:
+		return "release_year"
+	case FieldScreenPanel:
+		return "screen_panel"
+	case FieldStorageDriver:
+		return "storage_driver"
+	default:
+		return fmt.Sprintf("LaptopField(%d)", int(field))
+	}
+}
+
+// Range is an inclusive range of numbers, a nil bound is unbounded
+type Range struct {
+	Min *float64
+	Max *float64
+}
+
+// AtLeast returns the range of numbers greater than or equal to min
+func AtLeast(min float64) *Range {
+	return &Range{Min: &min}
+}
+
+// AtMost returns the range of numbers less than or equal to max
+func AtMost(max float64) *Range {
+	return &Range{Max: &max}
+}
+
+// Between returns the range of numbers from min to max, both included
+func Between(min, max float64) *Range {
+	return &Range{Min: &min, Max: &max}
+}
+
+// LaptopQuery selects, sorts and paginates laptops. Zero-valued fields don't constrain the result.
+type LaptopQuery struct {
+	Brand         string
+	ScreenPanel   pb.Screen_Panel
+	StorageDriver pb.Storage_Driver
+	CPUCores      *Range
+	RAMBytes      *Range
+	PriceUsd      *Range
+	ReleaseYear   *Range
+
+	// SortBy is the field to sort by, laptops with equal values are sorted by ID
+	SortBy     LaptopField
+	Descending bool
+
+	// Limit is the maximum number of laptops in a page, 0 means no limit
+	Limit int
+	// Cursor is the NextCursor of the previous page, empty for the first page
+	Cursor string
+}
+
+// LaptopPage is a page of laptops returned by a query
+type LaptopPage struct {
+	Laptops []*pb.Laptop
+	// NextCursor is the cursor of the next page, it is empty on the last page
+	NextCursor string
+}
+
+// IndexedLaptopStore stores laptop in memory, with an ordered index on every LaptopField
+type IndexedLaptopStore struct {
+	mutex   sync.RWMutex
+	data    map[string]*laptopRecord
+	indexes []*btree.BTree
+}
+
+// laptopRecord is a stored laptop with its index keys, by field
+type laptopRecord struct {
+	laptop *pb.Laptop
+	keys   [][]indexKey
+}
+
+// sampleSize is the number of laptops the query planner looks at to estimate the selectivity of conditions
+const sampleSize = 512
+
+// NewIndexedLaptopStore returns a new IndexedLaptopStore
+func NewIndexedLaptopStore() *IndexedLaptopStore {
+	indexes := make([]*btree.BTree, len(laptopFields))
+	for _, field := range laptopFields {
+		indexes[field] = btree.New(32)
+	}
+
+	return &IndexedLaptopStore{
+		data:    make(map[string]*laptopRecord),
+		indexes: indexes,
+	}
+}
+
+// Save saves the laptop to the store
+func (store *IndexedLaptopStore) Save(laptop *pb.Laptop) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.data[laptop.Id] != nil {
+		return ErrAlreadyExists
+	}
+
+	store.insert(deepCopy(laptop))
+	return nil
+}
+
+// insert adds a laptop to the data and the indexes
+func (store *IndexedLaptopStore) insert(laptop *pb.Laptop) {
+	record := &laptopRecord{
+		laptop: laptop,
+		keys:   make([][]indexKey, len(laptopFields)),
+	}
+	for _, field := range laptopFields {
+		record.keys[field] = laptopKeys(field, laptop)
+		for _, key := range record.keys[field] {
+			store.indexes[field].ReplaceOrInsert(indexEntry{key: key, id: laptop.Id})
+		}
+	}
+	store.data[laptop.Id] = record
+}
+
+// remove removes a laptop from the data and the indexes
+func (store *IndexedLaptopStore) remove(record *laptopRecord) {
+	for _, field := range laptopFields {
+		for _, key := range record.keys[field] {
+			store.indexes[field].Delete(indexEntry{key: key, id: record.laptop.Id})
+		}
+	}
+	delete(store.data, record.laptop.Id)
+}
+
+// Find finds a laptop by ID
+func (store *IndexedLaptopStore) Find(id string) (*pb.Laptop, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	record := store.data[id]
+	if record == nil {
+		return nil, nil
+	}
+
+	return deepCopy(record.laptop), nil
+}
+
+// Delete deletes a laptop by ID
+func (store *IndexedLaptopStore) Delete(id string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	record := store.data[id]
+	if record == nil {
+		return ErrNotFound
+	}
+
+	store.remove(record)
+	return nil
+}
+
+// Update updates a laptop by ID
+func (store *IndexedLaptopStore) Update(id string, update func(laptop *pb.Laptop) error) (*pb.Laptop, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	record := store.data[id]
+	if record == nil {
+		return nil, ErrNotFound
+	}
+
+	updated, err := updateCopy(record.laptop, update)
+	if err != nil {
+		return nil, err
+	}
+
+	store.remove(record)
+	store.insert(updated)
+	return deepCopy(updated), nil
+}
+
+// Search searches for laptops with filter, using the indexes for every filter field but the CPU frequency
+func (store *IndexedLaptopStore) Search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) error {
+	query := &LaptopQuery{}
+	if filter.GetMaxPriceUsd() > 0 {
+		query.PriceUsd = AtMost(filter.GetMaxPriceUsd())
+	}
+	if filter.GetMinCpuCores() > 0 {
+		query.CPUCores = AtLeast(float64(filter.GetMinCpuCores()))
+	}
+	if filter.GetMinRam() != nil {
+		query.RAMBytes = AtLeast(memoryKey(filter.GetMinRam()).number)
+	}
+
+	page, err := store.Query(query)
+	if err != nil {
+		return err
+	}
+
+	for _, laptop := range page.Laptops {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// 索引只用于缩小范围，最终以 isQualified 的精确判断为准
+		if !isQualified(filter, laptop) {
+			continue
+		}
+		if err := found(laptop); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Query returns a page of the laptops matching query
+func (store *IndexedLaptopStore) Query(query *LaptopQuery) (*LaptopPage, error) {
+	if query.SortBy < 0 || int(query.SortBy) >= len(laptopFields) {
+		return nil, fmt.Errorf("cannot sort by unknown field %v", query.SortBy)
+	}
+
+	after, err := decodeCursor(query.Cursor, query.SortBy, query.Descending)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := query.conditions()
+
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = len(store.data)
+	}
+
+	var records []*laptopRecord
+	if best := store.planQuery(conditions, limit+1); best != nil {
+		records = store.queryCandidates(best, conditions, query, after, limit+1)
+	} else {
+		records = store.queryOrdered(conditions, query, after, limit+1)
+	}
+
+	page := &LaptopPage{}
+	if len(records) > limit {
+		records = records[:limit]
+		last := records[limit-1]
+		page.NextCursor, err = encodeCursor(query.SortBy, query.Descending,
+			last.sortKey(query.SortBy, query.Descending), last.laptop.Id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	page.Laptops = make([]*pb.Laptop, len(records))
+	for i, record := range records {
+		page.Laptops[i] = deepCopy(record.laptop)
+	}
+	return page, nil
+}
+
+// planQuery returns the condition whose matches should be collected and
+// sorted, or nil if it is cheaper to walk the index of the sort field and stop
+// after limit matches. Costs are estimated on a sample of the laptops: IDs are
+// random UUIDs, so the first laptops in ID order are a uniform sample.
+func (store *IndexedLaptopStore) planQuery(conditions []condition, limit int) *condition {
+	if len(conditions) == 0 {
+		return nil
+	}
+
+	sampled, matchedAll := 0, 0
+	matched := make([]int, len(conditions))
+	store.indexes[FieldID].Ascend(func(item btree.Item) bool {
+		record := store.data[item.(indexEntry).id]
+		all := true
+		for i := range conditions {
+			if conditions[i].matchesRecord(record) {
+				matched[i]++
+			} else {
+				all = false
+			}
+		}
+		if all {
+			matchedAll++
+		}
+		sampled++
+		return sampled < sampleSize
+	})
+	if sampled == 0 {
+		return nil
+	}
+
+	n := float64(len(store.data))
+	best := 0
+	for i := range conditions {
+		if matched[i] < matched[best] {
+			best = i
+		}
+	}
+
+	// 有序扫描需要访问约 limit/选择率 个条目，候选集合则需要访问最优条件匹配的全部条目
+	collectCost := n * float64(matched[best]) / float64(sampled)
+	scanCost := math.Min(n, float64(limit)*float64(sampled)/float64(matchedAll+1))
+	if collectCost < scanCost {
+		return &conditions[best]
+	}
+	return nil
+}
+
+// queryOrdered walks the index of the sort field from the cursor and returns the IDs of the first matching laptops
+func (store *IndexedLaptopStore) queryOrdered(
+	conditions []condition,
+	query *LaptopQuery,
+	after *indexEntry,
+	limit int,
+) []*laptopRecord {
+	var records []*laptopRecord
+	visit := func(item btree.Item) bool {
+		entry := item.(indexEntry)
+		if after != nil && entry == *after {
+			return true
+		}
+
+		record := store.data[entry.id]
+		// 多值字段的笔记本在索引中出现多次，只在它的排序键处返回一次
+		if record.sortKey(query.SortBy, query.Descending) != entry.key {
+			return true
+		}
+		if record.matchesAll(conditions) {
+			records = append(records, record)
+		}
+		return len(records) < limit
+	}
+
+	index := store.indexes[query.SortBy]
+	switch {
+	case query.Descending && after != nil:
+		index.DescendLessOrEqual(*after, visit)
+	case query.Descending:
+		index.Descend(visit)
+	case after != nil:
+		index.AscendGreaterOrEqual(*after, visit)
+	default:
+		index.Ascend(visit)
+	}
+	return records
+}
+
+// queryCandidates collects the laptops matching the most selective condition, then filters and sorts them
+func (store *IndexedLaptopStore) queryCandidates(
+	best *condition,
+	conditions []condition,
+	query *LaptopQuery,
+	after *indexEntry,
+	limit int,
+) []*laptopRecord {
+	type candidate struct {
+		entry  indexEntry
+		record *laptopRecord
+	}
+
+	var candidates []candidate
+	seen := make(map[string]bool)
+
+	best.ascend(store.indexes[best.field], func(entry indexEntry) bool {
+		if seen[entry.id] {
+			return true
+		}
+		seen[entry.id] = true
+
+		record := store.data[entry.id]
+		if !record.matchesAll(conditions) {
+			return true
+		}
+
+		position := indexEntry{key: record.sortKey(query.SortBy, query.Descending), id: entry.id}
+		if after != nil {
+			if query.Descending && !position.Less(*after) {
+				return true
+			}
+			if !query.Descending && !after.Less(position) {
+				return true
+			}
+		}
+		candidates = append(candidates, candidate{entry: position, record: record})
+		return true
+	})
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if query.Descending {
+			return candidates[j].entry.Less(candidates[i].entry)
+		}
+		return candidates[i].entry.Less(candidates[j].entry)
+	})
+
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	records := make([]*laptopRecord, len(candidates))
+	for i, candidate := range candidates {
+		records[i] = candidate.record
+	}
+	return records
+}
+
+// indexKey is the value of a laptop field in an index, numbers sort before texts
+type indexKey struct {
+	number float64
+	text   string
+}
+
+func (key indexKey) compare(other indexKey) int {
+	switch {
+	case key.number < other.number:
+		return -1
+	case key.number > other.number:
+		return 1
+	case key.text < other.text:
+		return -1
+	case key.text > other.text:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// indexEntry is an entry of an index, entries are sorted by key then by laptop ID
+type indexEntry struct {
+	key indexKey
+	id  string
+}
+
+// Less implements btree.Item
+func (entry indexEntry) Less(than btree.Item) bool {
+	other := than.(indexEntry)
+	if c := entry.key.compare(other.key); c != 0 {
+		return c < 0
+	}
+	return entry.id < other.id
+}
+
+// laptopKeys returns the index keys of a laptop field, there is more than one for multi-valued fields
+func laptopKeys(field LaptopField, laptop *pb.Laptop) []indexKey {
+	switch field {
+	case FieldID:
+		return []indexKey{{text: laptop.GetId()}}
+	case FieldBrand:
+		return []indexKey{{text: laptop.GetBrand()}}
+	case FieldCPUCores:
+		return []indexKey{{number: float64(laptop.GetCpu().GetNumberCores())}}
+	case FieldRAMBytes:
+		return []indexKey{memoryKey(laptop.GetRam())}
+	case FieldPrice:
+		return []indexKey{{number: laptop.GetPriceUsd()}}
+	case FieldReleaseYear:
+		return []indexKey{{number: float64(laptop.GetReleaseYear())}}
+	case FieldScreenPanel:
+		return []indexKey{{number: float64(laptop.GetScreen().GetPanel())}}
+	case FieldStorageDriver:
+		drivers := make(map[pb.Storage_Driver]bool)
+		var keys []indexKey
+		for _, storage := range laptop.GetStorages() {
+			if !drivers[storage.GetDriver()] {
+				drivers[storage.GetDriver()] = true
+				keys = append(keys, indexKey{number: float64(storage.GetDriver())})
+			}
+		}
+		if len(keys) == 0 {
+			keys = append(keys, indexKey{number: float64(pb.Storage_UNKNOWN)})
+		}
+		return keys
+	default:
+		return nil
+	}
+}
+
+// memoryKey returns the index key of a memory size in bytes, sizes too large for uint64 sort last
+// and sizes that are not a whole number of bytes keep their fraction, so that a range on the
+// key selects the same laptops as memory.Compare
+func memoryKey(size *pb.Memory) indexKey {
+	bytes, err := memory.Bytes(size)
+	if errors.Is(err, memory.ErrOverflow) {
+		return indexKey{number: math.Inf(1)}
+	}
+	if errors.Is(err, memory.ErrNotWholeBytes) {
+		// 只有以比特为单位的大小才可能不是整字节，换算成比特不会溢出
+		if bits, err := memory.Bits(size); err == nil {
+			return indexKey{number: float64(bits) / 8}
+		}
+	}
+	return indexKey{number: float64(bytes)}
+}
+
+// sortKey returns the key a laptop is sorted by: its smallest key in ascending order, and its largest one in descending order
+func sortKey(field LaptopField, laptop *pb.Laptop, descending bool) indexKey {
+	return minMaxKey(laptopKeys(field, laptop), descending)
+}
+
+func (record *laptopRecord) sortKey(field LaptopField, descending bool) indexKey {
+	return minMaxKey(record.keys[field], descending)
+}
+
+func minMaxKey(keys []indexKey, descending bool) indexKey {
+	key := keys[0]
+	for _, other := range keys[1:] {
+		if c := other.compare(key); (c < 0 && !descending) || (c > 0 && descending) {
+			key = other
+		}
+	}
+	return key
+}
+
+// condition constrains a field to an inclusive range of keys
+type condition struct {
+	field LaptopField
+	min   *indexKey
+	max   *indexKey
+}
+
+func (query *LaptopQuery) conditions() []condition {
+	var conditions []condition
+
+	equal := func(field LaptopField, key indexKey) {
+		conditions = append(conditions, condition{field: field, min: &key, max: &key})
+	}
+	between := func(field LaptopField, r *Range) {
+		c := condition{field: field}
+		if r.Min != nil {
+			c.min = &indexKey{number: *r.Min}
+		}
+		if r.Max != nil {
+			c.max = &indexKey{number: *r.Max}
+		}
+		conditions = append(conditions, c)
+	}
+
+	if query.Brand != "" {
+		equal(FieldBrand, indexKey{text: query.Brand})
+	}
+	if query.ScreenPanel != pb.Screen_UNKNOWN {
+		equal(FieldScreenPanel, indexKey{number: float64(query.ScreenPanel)})
+	}
+	if query.StorageDriver != pb.Storage_UNKNOWN {
+		equal(FieldStorageDriver, indexKey{number: float64(query.StorageDriver)})
+	}
+	if query.CPUCores != nil {
+		between(FieldCPUCores, query.CPUCores)
+	}
+	if query.RAMBytes != nil {
+		between(FieldRAMBytes, query.RAMBytes)
+	}
+	if query.PriceUsd != nil {
+		between(FieldPrice, query.PriceUsd)
+	}
+	if query.ReleaseYear != nil {
+		between(FieldReleaseYear, query.ReleaseYear)
+	}
+	return conditions
+}
+
+func (c *condition) matches(key indexKey) bool {
+	if c.min != nil && key.compare(*c.min) < 0 {
+		return false
+	}
+	if c.max != nil && key.compare(*c.max) > 0 {
+		return false
+	}
+	return true
+}
+
+func (c *condition) matchesRecord(record *laptopRecord) bool {
+	for _, key := range record.keys[c.field] {
+		if c.matches(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (record *laptopRecord) matchesAll(conditions []condition) bool {
+	for i := range conditions {
+		if !conditions[i].matchesRecord(record) {
+			return false
+		}
+	}
+	return true
+}
+
+// ascend calls visit for the index entries within the condition range, in order, until visit returns false
+func (c *condition) ascend(index *btree.BTree, visit func(entry indexEntry) bool) {
+	iterator := func(item btree.Item) bool {
+		entry := item.(indexEntry)
+		if c.max != nil && entry.key.compare(*c.max) > 0 {
+			return false
+		}
+		return visit(entry)
+	}
+
+	if c.min != nil {
+		// ID为空字符串的条目排在同一个键的所有条目之前
+		index.AscendGreaterOrEqual(indexEntry{key: *c.min}, iterator)
+	} else {
+		index.Ascend(iterator)
+	}
+}
+
+// cursor is the position of the last laptop of a page, encoded as base64 JSON.
+// The number is stored as its IEEE 754 bits, JSON cannot represent the infinite
+// keys of memory sizes that overflow uint64.
+type cursor struct {
+	Field      LaptopField `json:"f"`
+	Descending bool        `json:"d,omitempty"`
+	NumberBits uint64      `json:"n,omitempty"`
+	Text       string      `json:"t,omitempty"`
+	ID         string      `json:"i"`
+}
+
+func encodeCursor(field LaptopField, descending bool, key indexKey, id string) (string, error) {
+	data, err := json.Marshal(cursor{
+		Field:      field,
+		Descending: descending,
+		NumberBits: math.Float64bits(key.number),
+		Text:       key.text,
+		ID:         id,
+	})
+	if err != nil {
+		return "", fmt.Errorf("cannot encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(s string, field LaptopField, descending bool) (*indexEntry, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	c := cursor{}
+	if err = json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if c.Field != field || c.Descending != descending {
+		return nil, fmt.Errorf("%w: cursor belongs to a query sorted by %v", ErrInvalidCursor, c.Field)
+	}
+
+	return &indexEntry{key: indexKey{number: math.Float64frombits(c.NumberBits), text: c.Text}, id: c.ID}, nil
+}
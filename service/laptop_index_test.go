@@ -0,0 +1,383 @@
+package service
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/Ruadgedy/pcbook/sample"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func newTestIndexedLaptops(t testing.TB, n int) []*pb.Laptop {
+	generator := sample.NewGenerator(20220501, func() time.Time {
+		return time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)
+	})
+
+	laptops := make([]*pb.Laptop, n)
+	for i := range laptops {
+		laptop := generator.NewLaptop()
+		// 让存储的组合多样化，覆盖多值字段和没有存储的情况
+		switch i % 4 {
+		case 1:
+			laptop.Storages = laptop.Storages[:1]
+		case 2:
+			laptop.Storages = laptop.Storages[1:]
+		case 3:
+			laptop.Storages = nil
+		}
+		laptops[i] = laptop
+	}
+	return laptops
+}
+
+// bruteForceQuery scans every laptop to answer the query, ignoring Limit and Cursor
+func bruteForceQuery(laptops []*pb.Laptop, query *LaptopQuery) []*pb.Laptop {
+	var result []*pb.Laptop
+	for _, laptop := range laptops {
+		if bruteForceMatches(laptop, query) {
+			result = append(result, laptop)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a := indexEntry{key: sortKey(query.SortBy, result[i], query.Descending), id: result[i].Id}
+		b := indexEntry{key: sortKey(query.SortBy, result[j], query.Descending), id: result[j].Id}
+		if query.Descending {
+			return b.Less(a)
+		}
+		return a.Less(b)
+	})
+	return result
+}
+
+func bruteForceMatches(laptop *pb.Laptop, query *LaptopQuery) bool {
+	inRange := func(r *Range, value float64) bool {
+		return r == nil || ((r.Min == nil || value >= *r.Min) && (r.Max == nil || value <= *r.Max))
+	}
+
+	if query.Brand != "" && laptop.Brand != query.Brand {
+		return false
+	}
+	if query.ScreenPanel != pb.Screen_UNKNOWN && laptop.Screen.Panel != query.ScreenPanel {
+		return false
+	}
+	if query.StorageDriver != pb.Storage_UNKNOWN {
+		found := false
+		for _, storage := range laptop.Storages {
+			found = found || storage.Driver == query.StorageDriver
+		}
+		if !found {
+			return false
+		}
+	}
+	return inRange(query.CPUCores, float64(laptop.Cpu.NumberCores)) &&
+		inRange(query.RAMBytes, float64(laptop.Ram.Value<<30)) &&
+		inRange(query.PriceUsd, laptop.PriceUsd) &&
+		inRange(query.ReleaseYear, float64(laptop.ReleaseYear))
+}
+
+func randomTestQuery(r *rand.Rand) *LaptopQuery {
+	randomRange := func(min, max float64) *Range {
+		a := min + r.Float64()*(max-min)
+		b := min + r.Float64()*(max-min)
+		if a > b {
+			a, b = b, a
+		}
+		switch r.Intn(4) {
+		case 0:
+			return nil
+		case 1:
+			return AtLeast(a)
+		case 2:
+			return AtMost(b)
+		default:
+			return Between(a, b)
+		}
+	}
+
+	query := &LaptopQuery{
+		CPUCores:    randomRange(2, 8),
+		RAMBytes:    randomRange(4<<30, 64<<30),
+		PriceUsd:    randomRange(1500, 3500),
+		ReleaseYear: randomRange(2015, 2019),
+		SortBy:      laptopFields[r.Intn(len(laptopFields))],
+		Descending:  r.Intn(2) == 0,
+		Limit:       r.Intn(50),
+	}
+	if r.Intn(2) == 0 {
+		query.Brand = []string{"Apple", "Dell", "Lenovo", "Acer"}[r.Intn(4)]
+	}
+	if r.Intn(3) == 0 {
+		query.ScreenPanel = pb.Screen_Panel(r.Intn(3))
+	}
+	if r.Intn(3) == 0 {
+		query.StorageDriver = pb.Storage_Driver(r.Intn(3))
+	}
+	return query
+}
+
+func TestIndexedLaptopStoreQuery(t *testing.T) {
+	t.Parallel()
+
+	laptops := newTestIndexedLaptops(t, 2000)
+	store := NewIndexedLaptopStore()
+	for _, laptop := range laptops {
+		require.NoError(t, store.Save(laptop))
+	}
+
+	r := rand.New(rand.NewSource(20220501))
+	for i := 0; i < 300; i++ {
+		query := randomTestQuery(r)
+		expected := bruteForceQuery(laptops, query)
+
+		var actual []*pb.Laptop
+		for pages := 0; ; pages++ {
+			require.LessOrEqual(t, pages, len(laptops), "query %+v does not terminate", query)
+
+			page, err := store.Query(query)
+			require.NoError(t, err)
+			if query.Limit > 0 {
+				require.LessOrEqual(t, len(page.Laptops), query.Limit)
+			}
+			actual = append(actual, page.Laptops...)
+
+			if page.NextCursor == "" {
+				break
+			}
+			query.Cursor = page.NextCursor
+		}
+
+		require.Equal(t, len(expected), len(actual), "query %+v", query)
+		for j := range expected {
+			require.Equal(t, expected[j].Id, actual[j].Id, "query %+v, laptop %d", query, j)
+		}
+	}
+}
+
+func TestIndexedLaptopStoreQueryPagination(t *testing.T) {
+	t.Parallel()
+
+	store := NewIndexedLaptopStore()
+	for _, laptop := range newTestIndexedLaptops(t, 10) {
+		require.NoError(t, store.Save(laptop))
+	}
+
+	query := &LaptopQuery{SortBy: FieldPrice, Limit: 4}
+	page, err := store.Query(query)
+	require.NoError(t, err)
+	require.Len(t, page.Laptops, 4)
+	require.NotEmpty(t, page.NextCursor)
+
+	query.Cursor = page.NextCursor
+	page, err = store.Query(query)
+	require.NoError(t, err)
+	require.Len(t, page.Laptops, 4)
+
+	query.Cursor = page.NextCursor
+	page, err = store.Query(query)
+	require.NoError(t, err)
+	require.Len(t, page.Laptops, 2)
+	require.Empty(t, page.NextCursor)
+
+	// 游标只能用于相同排序的查询
+	query.Descending = true
+	_, err = store.Query(query)
+	require.ErrorIs(t, err, ErrInvalidCursor)
+
+	query.Cursor = "not a cursor"
+	_, err = store.Query(query)
+	require.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestIndexedLaptopStoreQueryInfiniteCursor(t *testing.T) {
+	t.Parallel()
+
+	// 溢出uint64的内存大小在索引中的键是 +Inf，游标必须能表示它
+	store := NewIndexedLaptopStore()
+	expected := []string{}
+	for i := 0; i < 3; i++ {
+		laptop := sample.NewLaptop()
+		if i > 0 {
+			laptop.Ram = &pb.Memory{Value: math.MaxUint64, Unit: pb.Memory_TERABYTE}
+		}
+		require.NoError(t, store.Save(laptop))
+		expected = append(expected, laptop.Id)
+	}
+
+	for _, descending := range []bool{false, true} {
+		query := &LaptopQuery{SortBy: FieldRAMBytes, Descending: descending, Limit: 1}
+		found := []string{}
+		for {
+			page, err := store.Query(query)
+			require.NoError(t, err)
+			for _, laptop := range page.Laptops {
+				found = append(found, laptop.Id)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			query.Cursor = page.NextCursor
+		}
+		require.ElementsMatch(t, expected, found, "descending %v", descending)
+	}
+}
+
+func TestIndexedLaptopStoreSaveFindDelete(t *testing.T) {
+	t.Parallel()
+
+	store := NewIndexedLaptopStore()
+	laptop := sample.NewLaptop()
+
+	err := store.Save(laptop)
+	require.NoError(t, err)
+
+	err = store.Save(laptop)
+	require.ErrorIs(t, err, ErrAlreadyExists)
+
+	other, err := store.Find(laptop.Id)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(laptop, other))
+
+	err = store.Delete(laptop.Id)
+	require.NoError(t, err)
+
+	err = store.Delete(laptop.Id)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// 删除后索引中不应再有该笔记本
+	page, err := store.Query(&LaptopQuery{Brand: laptop.Brand})
+	require.NoError(t, err)
+	require.Empty(t, page.Laptops)
+}
+
+func TestIndexedLaptopStoreSearch(t *testing.T) {
+	t.Parallel()
+
+	laptops := newTestIndexedLaptops(t, 500)
+	indexed := NewIndexedLaptopStore()
+	inMemory := NewInMemoryLaptopStore()
+	for _, laptop := range laptops {
+		require.NoError(t, indexed.Save(laptop))
+		require.NoError(t, inMemory.Save(laptop))
+	}
+
+	filter := &pb.Filter{
+		MaxPriceUsd: 3000,
+		MinCpuCores: 4,
+		MinCpuGhz:   2.5,
+		MinRam:      &pb.Memory{Value: 16, Unit: pb.Memory_GIGABYTE},
+	}
+
+	search := func(store LaptopStore) map[string]bool {
+		found := make(map[string]bool)
+		err := store.Search(context.Background(), filter, func(laptop *pb.Laptop) error {
+			require.True(t, isQualified(filter, laptop))
+			found[laptop.Id] = true
+			return nil
+		})
+		require.NoError(t, err)
+		return found
+	}
+
+	expected := search(inMemory)
+	require.NotEmpty(t, expected)
+	require.Equal(t, expected, search(indexed))
+}
+
+func TestIndexedLaptopStoreSearchBits(t *testing.T) {
+	t.Parallel()
+
+	// 8 GB 加 1 比特不是整字节，但仍然满足 min_ram 为 8 GB 的过滤条件
+	laptop := sample.NewLaptop()
+	laptop.Ram = &pb.Memory{Value: 8<<33 + 1, Unit: pb.Memory_BIT}
+	smaller := sample.NewLaptop()
+	smaller.Ram = &pb.Memory{Value: 8<<33 - 1, Unit: pb.Memory_BIT}
+
+	indexed := NewIndexedLaptopStore()
+	inMemory := NewInMemoryLaptopStore()
+	for _, store := range []LaptopStore{indexed, inMemory} {
+		require.NoError(t, store.Save(laptop))
+		require.NoError(t, store.Save(smaller))
+	}
+
+	filter := &pb.Filter{MinRam: &pb.Memory{Value: 8, Unit: pb.Memory_GIGABYTE}}
+	for _, store := range []LaptopStore{indexed, inMemory} {
+		found := []string{}
+		err := store.Search(context.Background(), filter, func(laptop *pb.Laptop) error {
+			found = append(found, laptop.Id)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{laptop.Id}, found)
+	}
+}
+
+func benchmarkQueries() []*LaptopQuery {
+	return []*LaptopQuery{
+		{Brand: "Apple", CPUCores: AtLeast(6), SortBy: FieldPrice, Limit: 20},
+		{PriceUsd: Between(2000, 2010), SortBy: FieldRAMBytes, Descending: true, Limit: 20},
+		{ReleaseYear: AtLeast(2018), RAMBytes: AtLeast(48 << 30), SortBy: FieldPrice, Limit: 20},
+		{ScreenPanel: pb.Screen_OLED, StorageDriver: pb.Storage_SSD, SortBy: FieldCPUCores, Limit: 20},
+	}
+}
+
+func BenchmarkIndexedQuery(b *testing.B) {
+	store := NewIndexedLaptopStore()
+	for _, laptop := range newTestIndexedLaptops(b, 100000) {
+		require.NoError(b, store.Save(laptop))
+	}
+	queries := benchmarkQueries()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.Query(queries[i%len(queries)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBruteForceScan(b *testing.B) {
+	laptops := newTestIndexedLaptops(b, 100000)
+	queries := benchmarkQueries()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		query := queries[i%len(queries)]
+		result := bruteForceQuery(laptops, query)
+		if len(result) > query.Limit {
+			result = result[:query.Limit]
+		}
+	}
+}
+
+func TestIndexedLaptopStoreUpdate(t *testing.T) {
+	t.Parallel()
+
+	store := NewIndexedLaptopStore()
+	laptop := sample.NewLaptop()
+	laptop.Brand = "Apple"
+	require.NoError(t, store.Save(laptop))
+
+	updated, err := store.Update(laptop.Id, func(laptop *pb.Laptop) error {
+		laptop.Brand = "Dell"
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Dell", updated.Brand)
+
+	// 索引中的旧值需要被替换
+	page, err := store.Query(&LaptopQuery{Brand: "Apple"})
+	require.NoError(t, err)
+	require.Empty(t, page.Laptops)
+
+	page, err = store.Query(&LaptopQuery{Brand: "Dell"})
+	require.NoError(t, err)
+	require.Len(t, page.Laptops, 1)
+	require.True(t, proto.Equal(updated, page.Laptops[0]))
+}
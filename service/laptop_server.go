@@ -0,0 +1,476 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/Ruadgedy/pcbook/validate"
+	"github.com/google/uuid"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// DefaultMaxImageSize is the default maximum size of an uploaded laptop image: 1 megabyte
+const DefaultMaxImageSize = 1 << 20
+
+// MinLaptopScore and MaxLaptopScore bound the score accepted by RateLaptop
+const (
+	MinLaptopScore = 1
+	MaxLaptopScore = 10
+)
+
+// ErrVersionMismatch is returned when a laptop is updated from a stale version
+var ErrVersionMismatch = errors.New("laptop version mismatch")
+
+// immutableLaptopFields are the fields UpdateLaptop cannot change, the server maintains them
+var immutableLaptopFields = map[string]bool{
+	"id":         true,
+	"version":    true,
+	"updated_at": true,
+}
+
+// imageTypePattern limits image types to plain file extensions such as ".jpg"
+var imageTypePattern = regexp.MustCompile(`^\.[A-Za-z0-9]{1,10}$`)
+
+// LaptopServer is the server that provides laptop services
+type LaptopServer struct {
+	pb.UnimplementedLaptopServiceServer
+	laptopStore  LaptopStore
+	imageStore   ImageStore
+	ratingStore  RatingStore
+	maxImageSize int
+}
+
+// LaptopServerOption configures optional settings of a LaptopServer
+type LaptopServerOption func(server *LaptopServer)
+
+// WithMaxImageSize sets the maximum size in bytes of an uploaded laptop image
+func WithMaxImageSize(size int) LaptopServerOption {
+	return func(server *LaptopServer) {
+		server.maxImageSize = size
+	}
+}
+
+// NewLaptopServer returns a new LaptopServer
+func NewLaptopServer(
+	laptopStore LaptopStore,
+	imageStore ImageStore,
+	ratingStore RatingStore,
+	opts ...LaptopServerOption,
+) *LaptopServer {
+	server := &LaptopServer{
+		laptopStore:  laptopStore,
+		imageStore:   imageStore,
+		ratingStore:  ratingStore,
+		maxImageSize: DefaultMaxImageSize,
+	}
+	for _, opt := range opts {
+		opt(server)
+	}
+	return server
+}
+
+// CheckHealth returns an error if one of the stores of the server is not ready
+func (server *LaptopServer) CheckHealth(ctx context.Context) error {
+	return checkHealth(ctx,
+		namedComponent{"laptop store", server.laptopStore},
+		namedComponent{"image store", server.imageStore},
+		namedComponent{"rating store", server.ratingStore},
+	)
+}
+
+// CreateLaptop is a unary RPC to create a new laptop
+func (server *LaptopServer) CreateLaptop(
+	ctx context.Context,
+	req *pb.CreateLaptopRequest,
+) (*pb.CreateLaptopResponse, error) {
+	laptop := req.GetLaptop()
+	if laptop == nil {
+		return nil, status.Error(codes.InvalidArgument, "laptop is required")
+	}
+	log.Printf("receive a create-laptop request with id: %s", laptop.Id)
+
+	if err := validate.Laptop(laptop); err != nil {
+		return nil, logError(invalidLaptopError(err))
+	}
+
+	if len(laptop.Id) > 0 {
+		// 客户端指定了ID，检查是否是合法的UUID
+		_, err := uuid.Parse(laptop.Id)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "laptop ID is not a valid UUID: %v", err)
+		}
+	} else {
+		id, err := uuid.NewRandom()
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "cannot generate a new laptop ID: %v", err)
+		}
+		laptop.Id = id.String()
+	}
+
+	// 版本号和更新时间由服务端维护
+	laptop.Version = 1
+	laptop.UpdatedAt = timestamppb.Now()
+
+	if err := contextError(ctx); err != nil {
+		return nil, err
+	}
+
+	err := server.laptopStore.Save(laptop)
+	if err != nil {
+		code := codes.Internal
+		if errors.Is(err, ErrAlreadyExists) {
+			code = codes.AlreadyExists
+		}
+		return nil, status.Errorf(code, "cannot save laptop to the store: %v", err)
+	}
+
+	log.Printf("saved laptop with id: %s", laptop.Id)
+
+	res := &pb.CreateLaptopResponse{
+		Id: laptop.Id,
+	}
+	return res, nil
+}
+
+// GetLaptop is a unary RPC to get a laptop by ID
+func (server *LaptopServer) GetLaptop(
+	ctx context.Context,
+	req *pb.GetLaptopRequest,
+) (*pb.GetLaptopResponse, error) {
+	laptopID := req.GetId()
+	log.Printf("receive a get-laptop request with id: %s", laptopID)
+
+	if _, err := uuid.Parse(laptopID); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "laptop ID is not a valid UUID: %v", err)
+	}
+
+	if err := contextError(ctx); err != nil {
+		return nil, err
+	}
+
+	laptop, err := server.laptopStore.Find(laptopID)
+	if err != nil {
+		return nil, logError(status.Errorf(codes.Internal, "cannot find laptop: %v", err))
+	}
+	if laptop == nil {
+		return nil, logError(status.Errorf(codes.NotFound, "laptop id %s is not found", laptopID))
+	}
+
+	return &pb.GetLaptopResponse{Laptop: laptop}, nil
+}
+
+// SearchLaptop is a server-streaming RPC to search for laptops
+func (server *LaptopServer) SearchLaptop(
+	req *pb.SearchLaptopRequest,
+	stream pb.LaptopService_SearchLaptopServer,
+) error {
+	filter := req.GetFilter()
+	log.Printf("receive a search-laptop request with filter: %v", filter)
+
+	err := server.laptopStore.Search(
+		stream.Context(),
+		filter,
+		func(laptop *pb.Laptop) error {
+			res := &pb.SearchLaptopResponse{Laptop: laptop}
+			err := stream.Send(res)
+			if err != nil {
+				return err
+			}
+
+			log.Printf("sent laptop with id: %s", laptop.GetId())
+			return nil
+		},
+	)
+	if err != nil {
+		if ctxErr := contextError(stream.Context()); ctxErr != nil {
+			return ctxErr
+		}
+		return status.Errorf(codes.Internal, "unexpected error: %v", err)
+	}
+
+	return nil
+}
+
+// UploadImage is a client-streaming RPC to upload a laptop image
+func (server *LaptopServer) UploadImage(stream pb.LaptopService_UploadImageServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return logError(status.Errorf(codes.Unknown, "cannot receive image info: %v", err))
+	}
+
+	info := req.GetInfo()
+	if info == nil {
+		return logError(status.Error(codes.InvalidArgument, "first message must contain image info"))
+	}
+
+	laptopID := info.GetLaptopId()
+	imageType := info.GetImageType()
+	log.Printf("receive an upload-image request for laptop %s with image type %s", laptopID, imageType)
+
+	if !imageTypePattern.MatchString(imageType) {
+		return logError(status.Errorf(codes.InvalidArgument, "invalid image type: %q", imageType))
+	}
+
+	laptop, err := server.laptopStore.Find(laptopID)
+	if err != nil {
+		return logError(status.Errorf(codes.Internal, "cannot find laptop: %v", err))
+	}
+	if laptop == nil {
+		return logError(status.Errorf(codes.InvalidArgument, "laptop id %s doesn't exist", laptopID))
+	}
+
+	imageData := bytes.Buffer{}
+	imageSize := 0
+
+	for {
+		if err := contextError(stream.Context()); err != nil {
+			return err
+		}
+
+		log.Print("waiting to receive more data")
+
+		req, err := stream.Recv()
+		if err == io.EOF {
+			log.Print("no more data")
+			break
+		}
+		if err != nil {
+			return logError(status.Errorf(codes.Unknown, "cannot receive chunk data: %v", err))
+		}
+
+		if req.GetInfo() != nil {
+			return logError(status.Error(codes.InvalidArgument, "image info must only be sent once"))
+		}
+
+		chunk := req.GetChunkData()
+		size := len(chunk)
+		log.Printf("received a chunk with size: %d", size)
+
+		imageSize += size
+		if imageSize > server.maxImageSize {
+			return logError(status.Errorf(codes.InvalidArgument, "image is too large: %d > %d", imageSize, server.maxImageSize))
+		}
+
+		_, err = imageData.Write(chunk)
+		if err != nil {
+			return logError(status.Errorf(codes.Internal, "cannot write chunk data: %v", err))
+		}
+	}
+
+	imageID, err := server.imageStore.Save(laptopID, imageType, imageData)
+	if err != nil {
+		return logError(status.Errorf(codes.Internal, "cannot save image to the store: %v", err))
+	}
+
+	res := &pb.UploadImageResponse{
+		Id:   imageID,
+		Size: uint32(imageSize),
+	}
+
+	err = stream.SendAndClose(res)
+	if err != nil {
+		return logError(status.Errorf(codes.Unknown, "cannot send response: %v", err))
+	}
+
+	log.Printf("saved image with id: %s, size: %d", imageID, imageSize)
+	return nil
+}
+
+// RateLaptop is a bidirectional-streaming RPC that allows client to rate a stream of laptops
+// with a score, and returns a stream of average score for each of them
+func (server *LaptopServer) RateLaptop(stream pb.LaptopService_RateLaptopServer) error {
+	for {
+		if err := contextError(stream.Context()); err != nil {
+			return err
+		}
+
+		req, err := stream.Recv()
+		if err == io.EOF {
+			log.Print("no more data")
+			break
+		}
+		if err != nil {
+			return logError(status.Errorf(codes.Unknown, "cannot receive stream request: %v", err))
+		}
+
+		laptopID := req.GetLaptopId()
+		score := req.GetScore()
+		log.Printf("received a rate-laptop request: id = %s, score = %.2f", laptopID, score)
+
+		// NaN 与任何数比较都为 false，必须写成区间内的判断才能拒绝它
+		if !(score >= MinLaptopScore && score <= MaxLaptopScore) {
+			return logError(status.Errorf(codes.InvalidArgument, "score must be between %d and %d: %v", MinLaptopScore, MaxLaptopScore, score))
+		}
+
+		laptop, err := server.laptopStore.Find(laptopID)
+		if err != nil {
+			return logError(status.Errorf(codes.Internal, "cannot find laptop: %v", err))
+		}
+		if laptop == nil {
+			return logError(status.Errorf(codes.NotFound, "laptop id %s is not found", laptopID))
+		}
+
+		rating, err := server.ratingStore.Add(laptopID, score)
+		if err != nil {
+			return logError(status.Errorf(codes.Internal, "cannot add rating to the store: %v", err))
+		}
+
+		res := &pb.RateLaptopResponse{
+			LaptopId:     laptopID,
+			RatedCount:   rating.Count,
+			AverageScore: rating.Average(),
+		}
+
+		err = stream.Send(res)
+		if err != nil {
+			return logError(status.Errorf(codes.Unknown, "cannot send stream response: %v", err))
+		}
+	}
+
+	return nil
+}
+
+// UpdateLaptop is a unary RPC to update the fields of a laptop listed in a field mask.
+// The update is rejected with ABORTED if the laptop was changed since the caller read it.
+func (server *LaptopServer) UpdateLaptop(
+	ctx context.Context,
+	req *pb.UpdateLaptopRequest,
+) (*pb.UpdateLaptopResponse, error) {
+	changes := req.GetLaptop()
+	if changes == nil {
+		return nil, status.Error(codes.InvalidArgument, "laptop is required")
+	}
+	log.Printf("receive an update-laptop request with id: %s, mask: %v", changes.Id, req.GetUpdateMask().GetPaths())
+
+	if _, err := uuid.Parse(changes.Id); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "laptop ID is not a valid UUID: %v", err)
+	}
+
+	paths, err := updatePaths(req.GetUpdateMask().GetPaths())
+	if err != nil {
+		return nil, logError(status.Errorf(codes.InvalidArgument, "invalid update mask: %v", err))
+	}
+	// 先检查路径是否合法，避免把客户端的错误当作存储错误返回
+	if err = applyFieldMask(&pb.Laptop{}, changes, paths); err != nil {
+		return nil, logError(status.Errorf(codes.InvalidArgument, "invalid update mask: %v", err))
+	}
+
+	if err = contextError(ctx); err != nil {
+		return nil, err
+	}
+
+	changes = proto.Clone(changes).(*pb.Laptop)
+	updated, err := server.laptopStore.Update(changes.Id, func(laptop *pb.Laptop) error {
+		if laptop.Version != req.GetExpectedVersion() {
+			return fmt.Errorf("%w: current version is %d, expected %d",
+				ErrVersionMismatch, laptop.Version, req.GetExpectedVersion())
+		}
+
+		if err := applyFieldMask(laptop, changes, paths); err != nil {
+			return err
+		}
+		if err := validate.Laptop(laptop); err != nil {
+			return err
+		}
+
+		laptop.Version++
+		laptop.UpdatedAt = timestamppb.Now()
+		return nil
+	})
+	if err != nil {
+		var violations validate.Violations
+		switch {
+		case errors.Is(err, ErrNotFound):
+			return nil, logError(status.Errorf(codes.NotFound, "laptop id %s is not found", changes.Id))
+		case errors.Is(err, ErrVersionMismatch):
+			return nil, logError(status.Errorf(codes.Aborted, "cannot update laptop: %v", err))
+		case errors.As(err, &violations):
+			return nil, logError(invalidLaptopError(violations))
+		default:
+			return nil, logError(status.Errorf(codes.Internal, "cannot update laptop in the store: %v", err))
+		}
+	}
+
+	log.Printf("updated laptop with id: %s to version %d", updated.Id, updated.Version)
+	return &pb.UpdateLaptopResponse{Laptop: updated}, nil
+}
+
+// updatePaths returns the laptop fields to update, "*" stands for every mutable field
+func updatePaths(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("at least one path is required, use \"*\" to replace the whole laptop")
+	}
+
+	if len(paths) == 1 && paths[0] == "*" {
+		var all []string
+		fields := (&pb.Laptop{}).ProtoReflect().Descriptor().Fields()
+		for i := 0; i < fields.Len(); i++ {
+			name := string(fields.Get(i).Name())
+			if !immutableLaptopFields[name] {
+				all = append(all, name)
+			}
+		}
+		return all, nil
+	}
+
+	for _, path := range paths {
+		if immutableLaptopFields[strings.SplitN(path, ".", 2)[0]] {
+			return nil, fmt.Errorf("field %s cannot be updated", path)
+		}
+	}
+	return paths, nil
+}
+
+// invalidLaptopError converts validation violations into an InvalidArgument status
+// carrying a BadRequest detail with one field violation per invalid field
+func invalidLaptopError(err error) error {
+	st := status.New(codes.InvalidArgument, err.Error())
+
+	violations, ok := err.(validate.Violations)
+	if !ok {
+		return st.Err()
+	}
+
+	badRequest := &errdetails.BadRequest{}
+	for _, violation := range violations {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       violation.Field,
+			Description: violation.Description,
+		})
+	}
+
+	detailed, detailErr := st.WithDetails(badRequest)
+	if detailErr != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+func contextError(ctx context.Context) error {
+	switch ctx.Err() {
+	case context.Canceled:
+		return logError(status.Error(codes.Canceled, "request is canceled"))
+	case context.DeadlineExceeded:
+		return logError(status.Error(codes.DeadlineExceeded, "deadline is exceeded"))
+	default:
+		return nil
+	}
+}
+
+func logError(err error) error {
+	if err != nil {
+		log.Print(err)
+	}
+	return err
+}
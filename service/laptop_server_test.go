@@ -0,0 +1,371 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/Ruadgedy/pcbook/sample"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func TestServerCreateLaptop(t *testing.T) {
+	t.Parallel()
+
+	laptopNoID := sample.NewLaptop()
+	laptopNoID.Id = ""
+
+	laptopInvalidID := sample.NewLaptop()
+	laptopInvalidID.Id = "invalid-uuid"
+
+	laptopInvalid := sample.NewLaptop()
+	laptopInvalid.Cpu.MaxGhz = laptopInvalid.Cpu.MinGhz - 1
+
+	laptopDuplicateID := sample.NewLaptop()
+	storeDuplicateID := NewInMemoryLaptopStore()
+	err := storeDuplicateID.Save(laptopDuplicateID)
+	require.Nil(t, err)
+
+	testCases := []struct {
+		name   string
+		laptop *pb.Laptop
+		store  LaptopStore
+		code   codes.Code
+	}{
+		{
+			name:   "success_with_id",
+			laptop: sample.NewLaptop(),
+			store:  NewInMemoryLaptopStore(),
+			code:   codes.OK,
+		},
+		{
+			name:   "success_no_id",
+			laptop: laptopNoID,
+			store:  NewInMemoryLaptopStore(),
+			code:   codes.OK,
+		},
+		{
+			name:   "failure_invalid_id",
+			laptop: laptopInvalidID,
+			store:  NewInMemoryLaptopStore(),
+			code:   codes.InvalidArgument,
+		},
+		{
+			name:   "failure_invalid_laptop",
+			laptop: laptopInvalid,
+			store:  NewInMemoryLaptopStore(),
+			code:   codes.InvalidArgument,
+		},
+		{
+			name:   "failure_duplicate_id",
+			laptop: laptopDuplicateID,
+			store:  storeDuplicateID,
+			code:   codes.AlreadyExists,
+		},
+		{
+			name:   "failure_nil_laptop",
+			laptop: nil,
+			store:  NewInMemoryLaptopStore(),
+			code:   codes.InvalidArgument,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := &pb.CreateLaptopRequest{
+				Laptop: tc.laptop,
+			}
+
+			server := NewLaptopServer(tc.store, nil, nil)
+			res, err := server.CreateLaptop(context.Background(), req)
+			if tc.code == codes.OK {
+				require.NoError(t, err)
+				require.NotNil(t, res)
+				require.NotEmpty(t, res.Id)
+				if len(tc.laptop.Id) > 0 {
+					require.Equal(t, tc.laptop.Id, res.Id)
+				}
+			} else {
+				require.Error(t, err)
+				require.Nil(t, res)
+				st, ok := status.FromError(err)
+				require.True(t, ok)
+				require.Equal(t, tc.code, st.Code())
+			}
+		})
+	}
+}
+
+func TestServerCreateLaptopViolationDetails(t *testing.T) {
+	t.Parallel()
+
+	laptop := sample.NewLaptop()
+	laptop.PriceUsd = -1
+	laptop.Keyboard.Layout = pb.Keyboard_UNKNOWN
+
+	server := NewLaptopServer(NewInMemoryLaptopStore(), nil, nil)
+	_, err := server.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: laptop})
+	require.Error(t, err)
+
+	st := status.Convert(err)
+	require.Equal(t, codes.InvalidArgument, st.Code())
+	require.Len(t, st.Details(), 1)
+
+	badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+	require.True(t, ok)
+
+	fields := []string{}
+	for _, violation := range badRequest.GetFieldViolations() {
+		fields = append(fields, violation.GetField())
+	}
+	require.Equal(t, []string{"keyboard.layout", "price_usd"}, fields)
+}
+
+func TestServerGetLaptop(t *testing.T) {
+	t.Parallel()
+
+	laptop := sample.NewLaptop()
+	store := NewInMemoryLaptopStore()
+	require.NoError(t, store.Save(laptop))
+	server := NewLaptopServer(store, nil, nil)
+
+	testCases := []struct {
+		name string
+		id   string
+		code codes.Code
+	}{
+		{
+			name: "success",
+			id:   laptop.Id,
+			code: codes.OK,
+		},
+		{
+			name: "failure_invalid_id",
+			id:   "invalid-uuid",
+			code: codes.InvalidArgument,
+		},
+		{
+			name: "failure_not_found",
+			id:   sample.NewLaptop().Id,
+			code: codes.NotFound,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := server.GetLaptop(context.Background(), &pb.GetLaptopRequest{Id: tc.id})
+			require.Equal(t, tc.code, status.Code(err))
+			if tc.code == codes.OK {
+				require.True(t, proto.Equal(laptop, res.GetLaptop()))
+			} else {
+				require.Nil(t, res)
+			}
+		})
+	}
+}
+
+func TestServerUpdateLaptop(t *testing.T) {
+	t.Parallel()
+
+	// 只更新核心数时，线程数必须仍然不少于核心数
+	laptop := sample.NewLaptop()
+	laptop.Cpu.NumberThreads = 12
+
+	server := NewLaptopServer(NewInMemoryLaptopStore(), nil, nil)
+	created, err := server.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: laptop})
+	require.NoError(t, err)
+
+	changes := sample.NewLaptop()
+	changes.Id = created.Id
+	changes.PriceUsd = 1999
+	changes.Cpu.NumberCores = 4
+	changes.Cpu.NumberThreads = 8
+
+	invalidChanges := sample.NewLaptop()
+	invalidChanges.Id = created.Id
+	invalidChanges.PriceUsd = -1
+
+	testCases := []struct {
+		name    string
+		laptop  *pb.Laptop
+		paths   []string
+		version uint64
+		code    codes.Code
+	}{
+		{
+			name:    "failure_nil_laptop",
+			laptop:  nil,
+			paths:   []string{"price_usd"},
+			version: 1,
+			code:    codes.InvalidArgument,
+		},
+		{
+			name:    "failure_invalid_id",
+			laptop:  &pb.Laptop{Id: "invalid-uuid"},
+			paths:   []string{"price_usd"},
+			version: 1,
+			code:    codes.InvalidArgument,
+		},
+		{
+			name:    "failure_not_found",
+			laptop:  sample.NewLaptop(),
+			paths:   []string{"price_usd"},
+			version: 1,
+			code:    codes.NotFound,
+		},
+		{
+			name:    "failure_empty_mask",
+			laptop:  changes,
+			version: 1,
+			code:    codes.InvalidArgument,
+		},
+		{
+			name:    "failure_unknown_path",
+			laptop:  changes,
+			paths:   []string{"cpu.frequency"},
+			version: 1,
+			code:    codes.InvalidArgument,
+		},
+		{
+			name:    "failure_path_through_repeated_field",
+			laptop:  changes,
+			paths:   []string{"gpus.name"},
+			version: 1,
+			code:    codes.InvalidArgument,
+		},
+		{
+			name:    "failure_immutable_path",
+			laptop:  changes,
+			paths:   []string{"updated_at.seconds"},
+			version: 1,
+			code:    codes.InvalidArgument,
+		},
+		{
+			name:    "failure_invalid_result",
+			laptop:  invalidChanges,
+			paths:   []string{"price_usd"},
+			version: 1,
+			code:    codes.InvalidArgument,
+		},
+		{
+			name:    "failure_stale_version",
+			laptop:  changes,
+			paths:   []string{"price_usd"},
+			version: 0,
+			code:    codes.Aborted,
+		},
+	}
+
+	// 失败的用例都不应修改笔记本，最后再执行成功的更新
+	for _, tc := range testCases {
+		req := &pb.UpdateLaptopRequest{
+			Laptop:          tc.laptop,
+			UpdateMask:      &fieldmaskpb.FieldMask{Paths: tc.paths},
+			ExpectedVersion: tc.version,
+		}
+		res, err := server.UpdateLaptop(context.Background(), req)
+		require.Error(t, err, tc.name)
+		require.Nil(t, res, tc.name)
+		require.Equal(t, tc.code, status.Code(err), tc.name)
+	}
+
+	before, err := server.laptopStore.Find(created.Id)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), before.Version)
+
+	res, err := server.UpdateLaptop(context.Background(), &pb.UpdateLaptopRequest{
+		Laptop:          changes,
+		UpdateMask:      &fieldmaskpb.FieldMask{Paths: []string{"price_usd", "cpu.number_cores"}},
+		ExpectedVersion: 1,
+	})
+	require.NoError(t, err)
+
+	updated := res.GetLaptop()
+	require.Equal(t, uint64(2), updated.Version)
+	require.False(t, updated.UpdatedAt.AsTime().Before(before.UpdatedAt.AsTime()))
+	require.Equal(t, 1999.0, updated.PriceUsd)
+	require.Equal(t, uint32(4), updated.Cpu.NumberCores)
+	// 掩码之外的字段保持不变
+	require.Equal(t, before.Cpu.NumberThreads, updated.Cpu.NumberThreads)
+	require.Equal(t, before.Brand, updated.Brand)
+
+	found, err := server.laptopStore.Find(created.Id)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(updated, found))
+
+	// 旧版本号不能再次使用
+	_, err = server.UpdateLaptop(context.Background(), &pb.UpdateLaptopRequest{
+		Laptop:          changes,
+		UpdateMask:      &fieldmaskpb.FieldMask{Paths: []string{"price_usd"}},
+		ExpectedVersion: 1,
+	})
+	require.Equal(t, codes.Aborted, status.Code(err))
+}
+
+func TestServerUpdateLaptopReplace(t *testing.T) {
+	t.Parallel()
+
+	server := NewLaptopServer(NewInMemoryLaptopStore(), nil, nil)
+	created, err := server.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: sample.NewLaptop()})
+	require.NoError(t, err)
+
+	replacement := sample.NewLaptop()
+	replacement.Id = created.Id
+	replacement.Version = 42
+	replacement.Weight = &pb.Laptop_WeightLb{WeightLb: 4.4}
+
+	res, err := server.UpdateLaptop(context.Background(), &pb.UpdateLaptopRequest{
+		Laptop:          replacement,
+		UpdateMask:      &fieldmaskpb.FieldMask{Paths: []string{"*"}},
+		ExpectedVersion: 1,
+	})
+	require.NoError(t, err)
+
+	// 除了服务端维护的字段，其他字段全部被替换
+	expected := proto.Clone(replacement).(*pb.Laptop)
+	expected.Version = 2
+	expected.UpdatedAt = res.GetLaptop().GetUpdatedAt()
+	require.True(t, proto.Equal(expected, res.GetLaptop()), "expected %v, got %v", expected, res.GetLaptop())
+}
+
+func TestServerUpdateLaptopConcurrent(t *testing.T) {
+	t.Parallel()
+
+	server := NewLaptopServer(NewInMemoryLaptopStore(), nil, nil)
+	created, err := server.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: sample.NewLaptop()})
+	require.NoError(t, err)
+
+	// 基于同一个版本的并发更新只有一个能成功
+	const n = 10
+	codesCh := make(chan codes.Code, n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			_, err := server.UpdateLaptop(context.Background(), &pb.UpdateLaptopRequest{
+				Laptop:          &pb.Laptop{Id: created.Id, Name: fmt.Sprintf("name %d", i)},
+				UpdateMask:      &fieldmaskpb.FieldMask{Paths: []string{"name"}},
+				ExpectedVersion: 1,
+			})
+			codesCh <- status.Code(err)
+		}(i)
+	}
+
+	counts := make(map[codes.Code]int)
+	for i := 0; i < n; i++ {
+		counts[<-codesCh]++
+	}
+	require.Equal(t, map[codes.Code]int{codes.OK: 1, codes.Aborted: n - 1}, counts)
+}
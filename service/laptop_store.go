@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/Ruadgedy/pcbook/memory"
+	"github.com/Ruadgedy/pcbook/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrAlreadyExists is returned when a record with the same ID already exists in the store
+var ErrAlreadyExists = errors.New("record already exists")
+
+// ErrNotFound is returned when a record cannot be found in the store
+var ErrNotFound = errors.New("record not found")
+
+// LaptopStore is an interface to store laptop
+type LaptopStore interface {
+	// Save saves the laptop to the store
+	Save(laptop *pb.Laptop) error
+	// Find finds a laptop by ID, it returns nil if the laptop doesn't exist
+	Find(id string) (*pb.Laptop, error)
+	// Delete deletes a laptop by ID
+	Delete(id string) error
+	// Update calls update with a copy of the laptop with the given ID, and atomically saves the
+	// modified copy unless update returns an error. It returns ErrNotFound if the laptop doesn't exist.
+	Update(id string, update func(laptop *pb.Laptop) error) (*pb.Laptop, error)
+	// Search searches for laptops with filter, returns one by one via the found function
+	Search(ctx context.Context, filter *pb.Filter, found func(laptop *pb.Laptop) error) error
+}
+
+// InMemoryLaptopStore stores laptop in memory
+type InMemoryLaptopStore struct {
+	mutex sync.RWMutex
+	data  map[string]*pb.Laptop
+}
+
+// NewInMemoryLaptopStore returns a new InMemoryLaptopStore
+func NewInMemoryLaptopStore() *InMemoryLaptopStore {
+	return &InMemoryLaptopStore{
+		data: make(map[string]*pb.Laptop),
+	}
+}
+
+// Save saves the laptop to the store
+func (store *InMemoryLaptopStore) Save(laptop *pb.Laptop) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.data[laptop.Id] != nil {
+		return ErrAlreadyExists
+	}
+
+	// 深拷贝，避免调用方修改已保存的数据
+	store.data[laptop.Id] = deepCopy(laptop)
+	return nil
+}
+
+// Find finds a laptop by ID
+func (store *InMemoryLaptopStore) Find(id string) (*pb.Laptop, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	laptop := store.data[id]
+	if laptop == nil {
+		return nil, nil
+	}
+
+	return deepCopy(laptop), nil
+}
+
+// Delete deletes a laptop by ID
+func (store *InMemoryLaptopStore) Delete(id string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.data[id] == nil {
+		return ErrNotFound
+	}
+
+	delete(store.data, id)
+	return nil
+}
+
+// Update updates a laptop by ID
+func (store *InMemoryLaptopStore) Update(id string, update func(laptop *pb.Laptop) error) (*pb.Laptop, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	laptop := store.data[id]
+	if laptop == nil {
+		return nil, ErrNotFound
+	}
+
+	updated, err := updateCopy(laptop, update)
+	if err != nil {
+		return nil, err
+	}
+
+	store.data[id] = updated
+	return deepCopy(updated), nil
+}
+
+// Search searches for laptops with filter. It iterates over a snapshot of the IDs and reads
+// each laptop when it is reached, so found is called without holding the lock and sees the
+// changes made since the search started.
+func (store *InMemoryLaptopStore) Search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) error {
+	// 只在读锁内拷贝ID，回调时不持有锁，避免慢调用方阻塞写操作
+	store.mutex.RLock()
+	ids := make([]string, 0, len(store.data))
+	for id := range store.data {
+		ids = append(ids, id)
+	}
+	store.mutex.RUnlock()
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// 笔记本可能在搜索开始后被删除
+		laptop, err := store.Find(id)
+		if err != nil {
+			return err
+		}
+		if laptop == nil || !isQualified(filter, laptop) {
+			continue
+		}
+
+		if err = found(laptop); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isQualified reports whether the laptop satisfies every non-zero field of the filter
+func isQualified(filter *pb.Filter, laptop *pb.Laptop) bool {
+	if filter.GetMaxPriceUsd() > 0 && laptop.GetPriceUsd() > filter.GetMaxPriceUsd() {
+		return false
+	}
+
+	if laptop.GetCpu().GetNumberCores() < filter.GetMinCpuCores() {
+		return false
+	}
+
+	if laptop.GetCpu().GetMinGhz() < filter.GetMinCpuGhz() {
+		return false
+	}
+
+	if filter.GetMinRam() != nil && memory.Compare(laptop.GetRam(), filter.GetMinRam()) < 0 {
+		return false
+	}
+
+	return true
+}
+
+// updateCopy calls update with a copy of laptop and returns the updated copy, the ID cannot be changed
+func updateCopy(laptop *pb.Laptop, update func(laptop *pb.Laptop) error) (*pb.Laptop, error) {
+	updated := deepCopy(laptop)
+	if err := update(updated); err != nil {
+		return nil, err
+	}
+	if updated.GetId() != laptop.GetId() {
+		return nil, errors.New("cannot change the laptop ID")
+	}
+	return updated, nil
+}
+
+func deepCopy(laptop *pb.Laptop) *pb.Laptop {
+	return proto.Clone(laptop).(*pb.Laptop)
+}
@@ -0,0 +1,295 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/Ruadgedy/pcbook/sample"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestInMemoryLaptopStoreSaveFind(t *testing.T) {
+	t.Parallel()
+
+	store := NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+
+	err := store.Save(laptop)
+	require.NoError(t, err)
+
+	err = store.Save(laptop)
+	require.ErrorIs(t, err, ErrAlreadyExists)
+
+	other, err := store.Find(laptop.Id)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(laptop, other))
+
+	missing, err := store.Find(sample.NewLaptop().Id)
+	require.NoError(t, err)
+	require.Nil(t, missing)
+}
+
+func TestInMemoryLaptopStoreDeepCopy(t *testing.T) {
+	t.Parallel()
+
+	store := NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	brand := laptop.Brand
+	cores := laptop.Cpu.NumberCores
+
+	err := store.Save(laptop)
+	require.NoError(t, err)
+
+	// 保存后修改调用方的对象，不应影响存储的数据
+	laptop.Brand = "modified"
+	laptop.Cpu.NumberCores = 128
+
+	found, err := store.Find(laptop.Id)
+	require.NoError(t, err)
+	require.Equal(t, brand, found.Brand)
+	require.Equal(t, cores, found.Cpu.NumberCores)
+
+	// 修改读出的对象，同样不应影响存储的数据
+	found.Brand = "modified"
+	found.Cpu.NumberCores = 128
+
+	again, err := store.Find(laptop.Id)
+	require.NoError(t, err)
+	require.Equal(t, brand, again.Brand)
+	require.Equal(t, cores, again.Cpu.NumberCores)
+}
+
+func TestInMemoryLaptopStoreDelete(t *testing.T) {
+	t.Parallel()
+
+	store := NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+
+	err := store.Delete(laptop.Id)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	err = store.Save(laptop)
+	require.NoError(t, err)
+
+	err = store.Delete(laptop.Id)
+	require.NoError(t, err)
+
+	found, err := store.Find(laptop.Id)
+	require.NoError(t, err)
+	require.Nil(t, found)
+}
+
+func TestInMemoryLaptopStoreSearch(t *testing.T) {
+	t.Parallel()
+
+	filter := &pb.Filter{
+		MaxPriceUsd: 2000,
+		MinCpuCores: 4,
+		MinCpuGhz:   2.2,
+		MinRam:      &pb.Memory{Value: 8, Unit: pb.Memory_GIGABYTE},
+	}
+
+	store := NewInMemoryLaptopStore()
+	expectedIDs := make(map[string]bool)
+
+	for i := 0; i < 6; i++ {
+		laptop := sample.NewLaptop()
+
+		switch i {
+		case 0:
+			laptop.PriceUsd = 2500
+		case 1:
+			laptop.Cpu.NumberCores = 2
+		case 2:
+			laptop.Cpu.MinGhz = 2.0
+		case 3:
+			laptop.Ram = &pb.Memory{Value: 4096, Unit: pb.Memory_MEGABYTE}
+		case 4:
+			laptop.PriceUsd = 1999
+			laptop.Cpu.NumberCores = 4
+			laptop.Cpu.NumberThreads = 4
+			laptop.Cpu.MinGhz = 2.5
+			laptop.Cpu.MaxGhz = 4.5
+			laptop.Ram = &pb.Memory{Value: 16, Unit: pb.Memory_GIGABYTE}
+			expectedIDs[laptop.Id] = true
+		case 5:
+			// 8192 MB 与 8 GB 相等，应被视为满足条件
+			laptop.PriceUsd = 2000
+			laptop.Cpu.NumberCores = 6
+			laptop.Cpu.NumberThreads = 12
+			laptop.Cpu.MinGhz = 2.8
+			laptop.Cpu.MaxGhz = 5.0
+			laptop.Ram = &pb.Memory{Value: 8192, Unit: pb.Memory_MEGABYTE}
+			expectedIDs[laptop.Id] = true
+		}
+
+		err := store.Save(laptop)
+		require.NoError(t, err)
+	}
+
+	foundIDs := make(map[string]bool)
+	err := store.Search(context.Background(), filter, func(laptop *pb.Laptop) error {
+		foundIDs[laptop.Id] = true
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, expectedIDs, foundIDs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = store.Search(ctx, filter, func(laptop *pb.Laptop) error {
+		return nil
+	})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestInMemoryLaptopStoreSearchWhileIterating(t *testing.T) {
+	t.Parallel()
+
+	testSearchWhileIterating(t, NewInMemoryLaptopStore())
+}
+
+// testSearchWhileIterating checks that store calls back while it iterates: the laptops
+// deleted or updated by the first callback are seen as they are when they are reached
+func testSearchWhileIterating(t *testing.T, store LaptopStore) {
+	laptops := make([]*pb.Laptop, 10)
+	for i := range laptops {
+		laptops[i] = sample.NewLaptop()
+		laptops[i].PriceUsd = 1000
+		require.NoError(t, store.Save(laptops[i]))
+	}
+
+	filter := &pb.Filter{MaxPriceUsd: 2000}
+	var found []string
+	err := store.Search(context.Background(), filter, func(laptop *pb.Laptop) error {
+		if len(found) == 0 {
+			// 删除一半剩下的笔记本，另一半改成不满足条件的价格
+			deleted := 0
+			for _, other := range laptops {
+				if other.Id == laptop.Id {
+					continue
+				}
+				if deleted < len(laptops)/2 {
+					require.NoError(t, store.Delete(other.Id))
+					deleted++
+					continue
+				}
+				_, err := store.Update(other.Id, func(other *pb.Laptop) error {
+					other.PriceUsd = 3000
+					return nil
+				})
+				require.NoError(t, err)
+			}
+		}
+		found = append(found, laptop.Id)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+}
+
+func TestInMemoryLaptopStoreConcurrent(t *testing.T) {
+	t.Parallel()
+
+	store := NewInMemoryLaptopStore()
+	const writers = 8
+	const laptopsPerWriter = 50
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < laptopsPerWriter; i++ {
+				laptop := sample.NewLaptop()
+				if err := store.Save(laptop); err != nil {
+					t.Error(err)
+					return
+				}
+				// 保存后立即修改，检测是否与存储的数据共享内存
+				laptop.Brand = fmt.Sprintf("writer-%d", i)
+
+				found, err := store.Find(laptop.Id)
+				if err != nil || found == nil {
+					t.Errorf("cannot find laptop %s: %v", laptop.Id, err)
+					return
+				}
+				found.Name = "mutated"
+
+				if i%5 == 0 {
+					if err := store.Delete(laptop.Id); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			err := store.Search(context.Background(), nil, func(laptop *pb.Laptop) error {
+				laptop.Name = "mutated"
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+
+	count := 0
+	err := store.Search(context.Background(), nil, func(laptop *pb.Laptop) error {
+		require.NotEqual(t, "mutated", laptop.Name)
+		count++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, writers*laptopsPerWriter*4/5, count)
+}
+
+func TestInMemoryLaptopStoreUpdate(t *testing.T) {
+	t.Parallel()
+
+	store := NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+
+	_, err := store.Update(laptop.Id, func(laptop *pb.Laptop) error { return nil })
+	require.ErrorIs(t, err, ErrNotFound)
+
+	err = store.Save(laptop)
+	require.NoError(t, err)
+
+	updated, err := store.Update(laptop.Id, func(laptop *pb.Laptop) error {
+		laptop.Name = "updated"
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, "updated", updated.Name)
+
+	// update 返回错误或修改ID时不保存
+	_, err = store.Update(laptop.Id, func(laptop *pb.Laptop) error {
+		laptop.Name = "discarded"
+		return ErrVersionMismatch
+	})
+	require.ErrorIs(t, err, ErrVersionMismatch)
+
+	_, err = store.Update(laptop.Id, func(laptop *pb.Laptop) error {
+		laptop.Id = sample.NewLaptop().Id
+		return nil
+	})
+	require.Error(t, err)
+
+	found, err := store.Find(laptop.Id)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(updated, found))
+}
@@ -0,0 +1,57 @@
+package service
+
+import "sync"
+
+// RatingStore is an interface to store laptop ratings
+type RatingStore interface {
+	// Add adds a new laptop score to the store and returns its rating
+	Add(laptopID string, score float64) (*Rating, error)
+}
+
+// Rating contains the rating information of a laptop
+type Rating struct {
+	Count uint32
+	Sum   float64
+}
+
+// Average returns the average score of the rating
+func (rating *Rating) Average() float64 {
+	if rating.Count == 0 {
+		return 0
+	}
+	return rating.Sum / float64(rating.Count)
+}
+
+// InMemoryRatingStore stores laptop ratings in memory
+type InMemoryRatingStore struct {
+	mutex  sync.RWMutex
+	rating map[string]*Rating
+}
+
+// NewInMemoryRatingStore returns a new InMemoryRatingStore
+func NewInMemoryRatingStore() *InMemoryRatingStore {
+	return &InMemoryRatingStore{
+		rating: make(map[string]*Rating),
+	}
+}
+
+// Add adds a new laptop score to the store and returns its rating
+func (store *InMemoryRatingStore) Add(laptopID string, score float64) (*Rating, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	rating := store.rating[laptopID]
+	if rating == nil {
+		rating = &Rating{}
+		store.rating[laptopID] = rating
+	}
+
+	rating.Count++
+	rating.Sum += score
+
+	// 返回副本，避免调用方在锁外读取时与后续写入产生数据竞争
+	return &Rating{
+		Count: rating.Count,
+		Sum:   rating.Sum,
+	}, nil
+}
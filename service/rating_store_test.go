@@ -0,0 +1,33 @@
+package service
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/Ruadgedy/pcbook/sample"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryRatingStoreConcurrent(t *testing.T) {
+	t.Parallel()
+
+	store := NewInMemoryRatingStore()
+	laptopID := sample.NewLaptop().GetId()
+
+	const n = 100
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.Add(laptopID, 5)
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	rating, err := store.Add(laptopID, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, n+1, rating.Count)
+	require.InDelta(t, float64(n*5+10)/float64(n+1), rating.Average(), 1e-9)
+}
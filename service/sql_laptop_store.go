@@ -0,0 +1,626 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Ruadgedy/pcbook/memory"
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/mattn/go-sqlite3"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// SQLLaptopStore stores laptop in a SQL database, in normalised tables:
+// laptops, cpus, gpus, storages and screens.
+//
+// Enums are stored by name so the tables are easy to query, and memory values
+// are stored as signed 64-bit integers. The queries use ? placeholders, which
+// SQLite and MySQL drivers understand.
+//
+// Writes are serialised within the process, and the database should be opened
+// with SQLiteDSN so that readers and writers wait for each other's locks.
+type SQLLaptopStore struct {
+	// writeMutex serialises the write transactions, SQLite allows only one writer at a time
+	writeMutex sync.Mutex
+	db         *sql.DB
+}
+
+// sqliteBusyTimeout is how long a SQLite connection waits for a lock held by another one
+const sqliteBusyTimeout = 5 * time.Second
+
+// SQLiteDSN returns the data source name to open filename with the sqlite3 driver for a
+// SQLLaptopStore: transactions take the write lock when they begin instead of upgrading a
+// read lock, a connection waits for a lock instead of failing with "database is locked",
+// and the WAL journal lets readers run during a write.
+func SQLiteDSN(filename string) string {
+	// 文件名中的 ?、# 和 % 必须转义，否则会被当作URI的参数、片段或转义序列
+	path := (&url.URL{Path: filename}).EscapedPath()
+	return fmt.Sprintf("file:%s?_txlock=immediate&_busy_timeout=%d&_journal_mode=WAL",
+		path, sqliteBusyTimeout.Milliseconds())
+}
+
+// NewSQLLaptopStore returns a new SQLLaptopStore, it migrates the schema to the latest version
+func NewSQLLaptopStore(db *sql.DB) (*SQLLaptopStore, error) {
+	if err := migrate(context.Background(), db, sqlMigrations); err != nil {
+		return nil, err
+	}
+
+	return &SQLLaptopStore{db: db}, nil
+}
+
+// CheckHealth returns an error if the laptops table cannot be queried
+func (store *SQLLaptopStore) CheckHealth(ctx context.Context) error {
+	var one int
+	err := store.db.QueryRowContext(ctx, `SELECT 1 FROM laptops LIMIT 1`).Scan(&one)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	return err
+}
+
+// Save saves the laptop to the store, in one transaction
+func (store *SQLLaptopStore) Save(laptop *pb.Laptop) error {
+	store.writeMutex.Lock()
+	defer store.writeMutex.Unlock()
+
+	ctx := context.Background()
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists int
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM laptops WHERE id = ?`, laptop.GetId()).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("cannot check laptop: %w", err)
+	}
+	if exists > 0 {
+		return ErrAlreadyExists
+	}
+
+	if err = insertLaptop(ctx, tx, laptop); err != nil {
+		// 另一个进程可能在检查之后插入了相同的ID
+		if isPrimaryKeyViolation(err) {
+			return ErrAlreadyExists
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// Find finds a laptop by ID
+func (store *SQLLaptopStore) Find(id string) (*pb.Laptop, error) {
+	laptops, err := store.query(context.Background(), "l.id = ?", []interface{}{id})
+	if err != nil {
+		return nil, err
+	}
+	if len(laptops) == 0 {
+		return nil, nil
+	}
+
+	return laptops[0], nil
+}
+
+// Delete deletes a laptop by ID
+func (store *SQLLaptopStore) Delete(id string) error {
+	store.writeMutex.Lock()
+	defer store.writeMutex.Unlock()
+
+	ctx := context.Background()
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	deleted, err := deleteLaptop(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNotFound
+	}
+
+	return tx.Commit()
+}
+
+// Update updates a laptop by ID, in one transaction
+func (store *SQLLaptopStore) Update(id string, update func(laptop *pb.Laptop) error) (*pb.Laptop, error) {
+	store.writeMutex.Lock()
+	defer store.writeMutex.Unlock()
+
+	ctx := context.Background()
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	laptops, err := loadLaptops(ctx, tx, "l.id = ?", []interface{}{id})
+	if err != nil {
+		return nil, err
+	}
+	if len(laptops) == 0 {
+		return nil, ErrNotFound
+	}
+
+	updated, err := updateCopy(laptops[0], update)
+	if err != nil {
+		return nil, err
+	}
+
+	// 子表的行数可能变化，删除后重新插入整台笔记本
+	if _, err = deleteLaptop(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	if err = insertLaptop(ctx, tx, updated); err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("cannot commit transaction: %w", err)
+	}
+	return updated, nil
+}
+
+// Search searches for laptops with filter, the filter is translated to SQL. It walks the IDs
+// of the matching laptops with a cursor and reads each laptop when it is reached, so found is
+// called before the whole result set is loaded.
+func (store *SQLLaptopStore) Search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+) error {
+	where, args := filterToSQL(filter)
+	rows, err := store.db.QueryContext(ctx, laptopIDQuery+" WHERE "+where+" ORDER BY l.id", args...)
+	if err != nil {
+		return fmt.Errorf("cannot query laptops: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return fmt.Errorf("cannot scan laptop id: %w", err)
+		}
+
+		// 每台笔记本在自己的事务中读取，它可能在搜索开始后被修改或删除
+		laptops, err := store.query(ctx, "l.id = ?", []interface{}{id})
+		if err != nil {
+			return err
+		}
+		// SQL 条件可能比过滤器宽松（例如无法换算成字节的内存），以 isQualified 为准
+		if len(laptops) == 0 || !isQualified(filter, laptops[0]) {
+			continue
+		}
+
+		if err = found(laptops[0]); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("cannot query laptops: %w", err)
+	}
+	return nil
+}
+
+// isPrimaryKeyViolation reports whether err is caused by inserting a duplicate primary key
+func isPrimaryKeyViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+}
+
+// filterToSQL returns the WHERE clause selecting the laptops that may satisfy filter
+func filterToSQL(filter *pb.Filter) (string, []interface{}) {
+	conditions := []string{"1 = 1"}
+	var args []interface{}
+
+	if filter.GetMaxPriceUsd() > 0 {
+		conditions = append(conditions, "l.price_usd <= ?")
+		args = append(args, filter.GetMaxPriceUsd())
+	}
+	if filter.GetMinCpuCores() > 0 {
+		conditions = append(conditions, "c.number_cores >= ?")
+		args = append(args, filter.GetMinCpuCores())
+	}
+	if filter.GetMinCpuGhz() > 0 {
+		conditions = append(conditions, "c.min_ghz >= ?")
+		args = append(args, filter.GetMinCpuGhz())
+	}
+	if filter.GetMinRam() != nil {
+		if bytes, ok := memoryBytes(filter.GetMinRam()); ok {
+			conditions = append(conditions, "(l.ram_bytes IS NULL OR l.ram_bytes >= ?)")
+			args = append(args, bytes)
+		}
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+const laptopQuery = `
+	SELECT l.id, l.brand, l.name, l.ram_value, l.ram_unit, l.keyboard_layout, l.keyboard_backlit,
+		l.weight_kg, l.weight_lb, l.price_usd, l.release_year, l.updated_at_seconds, l.updated_at_nanos, l.version,
+		c.brand, c.name, c.number_cores, c.number_threads, c.min_ghz, c.max_ghz,
+		s.size_inch, s.resolution_width, s.resolution_height, s.panel, s.multitouch
+	FROM laptops l
+	LEFT JOIN cpus c ON c.laptop_id = l.id
+	LEFT JOIN screens s ON s.laptop_id = l.id`
+
+const laptopIDQuery = `
+	SELECT l.id
+	FROM laptops l
+	LEFT JOIN cpus c ON c.laptop_id = l.id
+	LEFT JOIN screens s ON s.laptop_id = l.id`
+
+// query returns the laptops matching the WHERE clause, reading the tables in one transaction
+func (store *SQLLaptopStore) query(ctx context.Context, where string, args []interface{}) ([]*pb.Laptop, error) {
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	laptops, err := loadLaptops(ctx, tx, where, args)
+	if err != nil {
+		return nil, err
+	}
+	return laptops, tx.Commit()
+}
+
+// loadLaptops returns the laptops matching the WHERE clause with their CPU, GPUs, storages and screen
+func loadLaptops(ctx context.Context, tx *sql.Tx, where string, args []interface{}) ([]*pb.Laptop, error) {
+	laptops, err := queryLaptops(ctx, tx, laptopQuery+" WHERE "+where+" ORDER BY l.id", args)
+	if err != nil {
+		return nil, err
+	}
+	if len(laptops) == 0 {
+		return nil, nil
+	}
+
+	byID := make(map[string]*pb.Laptop, len(laptops))
+	for _, laptop := range laptops {
+		byID[laptop.Id] = laptop
+	}
+
+	ids := laptopIDQuery + " WHERE " + where
+	if err = queryGPUs(ctx, tx, ids, args, byID); err != nil {
+		return nil, err
+	}
+	if err = queryStorages(ctx, tx, ids, args, byID); err != nil {
+		return nil, err
+	}
+	return laptops, nil
+}
+
+func queryLaptops(ctx context.Context, tx *sql.Tx, query string, args []interface{}) ([]*pb.Laptop, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot query laptops: %w", err)
+	}
+	defer rows.Close()
+
+	var laptops []*pb.Laptop
+	for rows.Next() {
+		var (
+			laptop                            = &pb.Laptop{}
+			version                           int64
+			ramValue                          sql.NullInt64
+			ramUnit, keyboardLayout           sql.NullString
+			keyboardBacklit                   sql.NullBool
+			weightKg, weightLb                sql.NullFloat64
+			updatedSeconds, updatedNanos      sql.NullInt64
+			cpuBrand, cpuName                 sql.NullString
+			cpuCores, cpuThreads              sql.NullInt64
+			cpuMinGhz, cpuMaxGhz              sql.NullFloat64
+			screenSize                        sql.NullFloat64
+			resolutionWidth, resolutionHeight sql.NullInt64
+			screenPanel                       sql.NullString
+			screenMultitouch                  sql.NullBool
+		)
+
+		err = rows.Scan(
+			&laptop.Id, &laptop.Brand, &laptop.Name, &ramValue, &ramUnit, &keyboardLayout, &keyboardBacklit,
+			&weightKg, &weightLb, &laptop.PriceUsd, &laptop.ReleaseYear, &updatedSeconds, &updatedNanos, &version,
+			&cpuBrand, &cpuName, &cpuCores, &cpuThreads, &cpuMinGhz, &cpuMaxGhz,
+			&screenSize, &resolutionWidth, &resolutionHeight, &screenPanel, &screenMultitouch,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("cannot scan laptop: %w", err)
+		}
+
+		laptop.Version = uint64(version)
+		laptop.Ram = scanMemory(ramValue, ramUnit)
+		if keyboardLayout.Valid {
+			laptop.Keyboard = &pb.Keyboard{
+				Layout:  pb.Keyboard_Layout(enumValue(keyboardLayout.String, pb.Keyboard_Layout_value)),
+				Backlit: keyboardBacklit.Bool,
+			}
+		}
+		if weightKg.Valid {
+			laptop.Weight = &pb.Laptop_WeightKg{WeightKg: weightKg.Float64}
+		} else if weightLb.Valid {
+			laptop.Weight = &pb.Laptop_WeightLb{WeightLb: weightLb.Float64}
+		}
+		if updatedSeconds.Valid {
+			laptop.UpdatedAt = &timestamppb.Timestamp{
+				Seconds: updatedSeconds.Int64,
+				Nanos:   int32(updatedNanos.Int64),
+			}
+		}
+		if cpuBrand.Valid {
+			laptop.Cpu = &pb.CPU{
+				Brand:         cpuBrand.String,
+				Name:          cpuName.String,
+				NumberCores:   uint32(cpuCores.Int64),
+				NumberThreads: uint32(cpuThreads.Int64),
+				MinGhz:        cpuMinGhz.Float64,
+				MaxGhz:        cpuMaxGhz.Float64,
+			}
+		}
+		if screenPanel.Valid {
+			laptop.Screen = &pb.Screen{
+				SizeInch:   float32(screenSize.Float64),
+				Panel:      pb.Screen_Panel(enumValue(screenPanel.String, pb.Screen_Panel_value)),
+				Multitouch: screenMultitouch.Bool,
+			}
+			if resolutionWidth.Valid {
+				laptop.Screen.Resolution = &pb.Screen_Resolution{
+					Width:  uint32(resolutionWidth.Int64),
+					Height: uint32(resolutionHeight.Int64),
+				}
+			}
+		}
+
+		laptops = append(laptops, laptop)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("cannot query laptops: %w", err)
+	}
+	return laptops, nil
+}
+
+func queryGPUs(ctx context.Context, tx *sql.Tx, ids string, args []interface{}, laptops map[string]*pb.Laptop) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT laptop_id, brand, name, min_ghz, max_ghz, memory_value, memory_unit
+		FROM gpus
+		WHERE laptop_id IN (`+ids+`)
+		ORDER BY laptop_id, position`,
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("cannot query gpus: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			laptopID    string
+			gpu         = &pb.GPU{}
+			memoryValue sql.NullInt64
+			memoryUnit  sql.NullString
+		)
+		err = rows.Scan(&laptopID, &gpu.Brand, &gpu.Name, &gpu.MinGhz, &gpu.MaxGhz, &memoryValue, &memoryUnit)
+		if err != nil {
+			return fmt.Errorf("cannot scan gpu: %w", err)
+		}
+
+		gpu.Memory = scanMemory(memoryValue, memoryUnit)
+		if laptop := laptops[laptopID]; laptop != nil {
+			laptop.Gpus = append(laptop.Gpus, gpu)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("cannot query gpus: %w", err)
+	}
+	return nil
+}
+
+func queryStorages(ctx context.Context, tx *sql.Tx, ids string, args []interface{}, laptops map[string]*pb.Laptop) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT laptop_id, driver, memory_value, memory_unit
+		FROM storages
+		WHERE laptop_id IN (`+ids+`)
+		ORDER BY laptop_id, position`,
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("cannot query storages: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			laptopID    string
+			driver      string
+			memoryValue sql.NullInt64
+			memoryUnit  sql.NullString
+		)
+		if err = rows.Scan(&laptopID, &driver, &memoryValue, &memoryUnit); err != nil {
+			return fmt.Errorf("cannot scan storage: %w", err)
+		}
+
+		storage := &pb.Storage{
+			Driver: pb.Storage_Driver(enumValue(driver, pb.Storage_Driver_value)),
+			Memory: scanMemory(memoryValue, memoryUnit),
+		}
+		if laptop := laptops[laptopID]; laptop != nil {
+			laptop.Storages = append(laptop.Storages, storage)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("cannot query storages: %w", err)
+	}
+	return nil
+}
+
+func insertLaptop(ctx context.Context, tx *sql.Tx, laptop *pb.Laptop) error {
+	ramValue, ramUnit := memoryColumns(laptop.GetRam())
+	var ramBytes interface{}
+	if bytes, ok := memoryBytes(laptop.GetRam()); ok {
+		ramBytes = bytes
+	}
+
+	var keyboardLayout, keyboardBacklit interface{}
+	if keyboard := laptop.GetKeyboard(); keyboard != nil {
+		keyboardLayout = keyboard.GetLayout().String()
+		keyboardBacklit = keyboard.GetBacklit()
+	}
+
+	var weightKg, weightLb interface{}
+	switch weight := laptop.GetWeight().(type) {
+	case *pb.Laptop_WeightKg:
+		weightKg = weight.WeightKg
+	case *pb.Laptop_WeightLb:
+		weightLb = weight.WeightLb
+	}
+
+	var updatedSeconds, updatedNanos interface{}
+	if updatedAt := laptop.GetUpdatedAt(); updatedAt != nil {
+		updatedSeconds = updatedAt.GetSeconds()
+		updatedNanos = updatedAt.GetNanos()
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO laptops (
+			id, brand, name, ram_value, ram_unit, ram_bytes, keyboard_layout, keyboard_backlit,
+			weight_kg, weight_lb, price_usd, release_year, updated_at_seconds, updated_at_nanos, version
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		laptop.GetId(), laptop.GetBrand(), laptop.GetName(), ramValue, ramUnit, ramBytes, keyboardLayout, keyboardBacklit,
+		weightKg, weightLb, laptop.GetPriceUsd(), laptop.GetReleaseYear(), updatedSeconds, updatedNanos,
+		int64(laptop.GetVersion()),
+	)
+	if err != nil {
+		return fmt.Errorf("cannot insert laptop: %w", err)
+	}
+
+	if cpu := laptop.GetCpu(); cpu != nil {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO cpus (laptop_id, brand, name, number_cores, number_threads, min_ghz, max_ghz)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			laptop.GetId(), cpu.GetBrand(), cpu.GetName(), cpu.GetNumberCores(), cpu.GetNumberThreads(),
+			cpu.GetMinGhz(), cpu.GetMaxGhz(),
+		)
+		if err != nil {
+			return fmt.Errorf("cannot insert cpu: %w", err)
+		}
+	}
+
+	for position, gpu := range laptop.GetGpus() {
+		memoryValue, memoryUnit := memoryColumns(gpu.GetMemory())
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO gpus (laptop_id, position, brand, name, min_ghz, max_ghz, memory_value, memory_unit)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			laptop.GetId(), position, gpu.GetBrand(), gpu.GetName(), gpu.GetMinGhz(), gpu.GetMaxGhz(),
+			memoryValue, memoryUnit,
+		)
+		if err != nil {
+			return fmt.Errorf("cannot insert gpu: %w", err)
+		}
+	}
+
+	for position, storage := range laptop.GetStorages() {
+		memoryValue, memoryUnit := memoryColumns(storage.GetMemory())
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO storages (laptop_id, position, driver, memory_value, memory_unit)
+			VALUES (?, ?, ?, ?, ?)`,
+			laptop.GetId(), position, storage.GetDriver().String(), memoryValue, memoryUnit,
+		)
+		if err != nil {
+			return fmt.Errorf("cannot insert storage: %w", err)
+		}
+	}
+
+	if screen := laptop.GetScreen(); screen != nil {
+		var width, height interface{}
+		if resolution := screen.GetResolution(); resolution != nil {
+			width, height = resolution.GetWidth(), resolution.GetHeight()
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO screens (laptop_id, size_inch, resolution_width, resolution_height, panel, multitouch)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			laptop.GetId(), float64(screen.GetSizeInch()), width, height, screen.GetPanel().String(),
+			screen.GetMultitouch(),
+		)
+		if err != nil {
+			return fmt.Errorf("cannot insert screen: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// deleteLaptop deletes a laptop and its rows in the other tables, it reports whether the laptop existed
+func deleteLaptop(ctx context.Context, tx *sql.Tx, id string) (bool, error) {
+	// 不依赖外键级联删除，SQLite 默认不开启外键约束
+	for _, table := range []string{"cpus", "gpus", "storages", "screens"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE laptop_id = ?`, id); err != nil {
+			return false, fmt.Errorf("cannot delete from %s: %w", table, err)
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM laptops WHERE id = ?`, id)
+	if err != nil {
+		return false, fmt.Errorf("cannot delete laptop: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("cannot delete laptop: %w", err)
+	}
+	return deleted > 0, nil
+}
+
+// memoryColumns returns the value and unit columns of a memory, they are NULL if the memory is nil
+func memoryColumns(size *pb.Memory) (interface{}, interface{}) {
+	if size == nil {
+		return nil, nil
+	}
+	// 超过 int64 范围的值按位保存为负数，读回时还原
+	return int64(size.GetValue()), size.GetUnit().String()
+}
+
+func scanMemory(value sql.NullInt64, unit sql.NullString) *pb.Memory {
+	if !unit.Valid {
+		return nil
+	}
+	return &pb.Memory{
+		Value: uint64(value.Int64),
+		Unit:  pb.Memory_Unit(enumValue(unit.String, pb.Memory_Unit_value)),
+	}
+}
+
+// memoryBytes returns the size of memory in bytes if it is a whole number of bytes that fits in int64
+func memoryBytes(size *pb.Memory) (int64, bool) {
+	bytes, err := memory.Bytes(size)
+	if err != nil || bytes > math.MaxInt64 {
+		return 0, false
+	}
+	return int64(bytes), true
+}
+
+// enumValue returns the number of an enum value stored by name, unknown numbers are stored as decimal strings
+func enumValue(name string, values map[string]int32) int32 {
+	if value, ok := values[name]; ok {
+		return value
+	}
+	value, _ := strconv.ParseInt(name, 10, 32)
+	return int32(value)
+}
@@ -0,0 +1,336 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/Ruadgedy/pcbook/sample"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func openTestSQLDatabase(t *testing.T) *sql.DB {
+	return openSQLDatabase(t, filepath.Join(t.TempDir(), "laptops.db"))
+}
+
+func openSQLDatabase(t *testing.T, filename string) *sql.DB {
+	db, err := sql.Open("sqlite3", SQLiteDSN(filename))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSQLLaptopStoreSaveFindDelete(t *testing.T) {
+	t.Parallel()
+
+	store, err := NewSQLLaptopStore(openTestSQLDatabase(t))
+	require.NoError(t, err)
+
+	// 覆盖可选字段为空、另一种重量单位和未知枚举值的情况
+	sparse := sample.NewLaptop()
+	sparse.Cpu = nil
+	sparse.Ram = nil
+	sparse.Gpus = nil
+	sparse.Screen.Resolution = nil
+	sparse.Keyboard = nil
+	sparse.Weight = &pb.Laptop_WeightLb{WeightLb: 4.4}
+	sparse.UpdatedAt = nil
+	sparse.Storages[0].Driver = pb.Storage_Driver(42)
+
+	laptops := []*pb.Laptop{sample.NewLaptop(), sparse}
+	for _, laptop := range laptops {
+		require.NoError(t, store.Save(laptop))
+		require.ErrorIs(t, store.Save(laptop), ErrAlreadyExists)
+
+		found, err := store.Find(laptop.Id)
+		require.NoError(t, err)
+		require.True(t, proto.Equal(laptop, found), "expected %v, got %v", laptop, found)
+	}
+
+	missing, err := store.Find(sample.NewLaptop().Id)
+	require.NoError(t, err)
+	require.Nil(t, missing)
+
+	require.NoError(t, store.Delete(laptops[0].Id))
+	require.ErrorIs(t, store.Delete(laptops[0].Id), ErrNotFound)
+
+	found, err := store.Find(laptops[0].Id)
+	require.NoError(t, err)
+	require.Nil(t, found)
+
+	// 删除后子表中不应残留数据，同一个ID可以重新保存
+	require.NoError(t, store.Save(laptops[0]))
+	found, err = store.Find(laptops[0].Id)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(laptops[0], found))
+}
+
+func TestSQLLaptopStoreUpdate(t *testing.T) {
+	t.Parallel()
+
+	db := openTestSQLDatabase(t)
+	store, err := NewSQLLaptopStore(db)
+	require.NoError(t, err)
+
+	laptop := sample.NewLaptop()
+	laptop.Version = 1
+	require.NoError(t, store.Save(laptop))
+
+	_, err = store.Update(sample.NewLaptop().Id, func(laptop *pb.Laptop) error { return nil })
+	require.ErrorIs(t, err, ErrNotFound)
+
+	updated, err := store.Update(laptop.Id, func(laptop *pb.Laptop) error {
+		laptop.Storages = laptop.Storages[:1]
+		laptop.Cpu = nil
+		laptop.Version++
+		return nil
+	})
+	require.NoError(t, err)
+
+	found, err := store.Find(laptop.Id)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(updated, found), "expected %v, got %v", updated, found)
+
+	var storages, cpus int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM storages`).Scan(&storages))
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM cpus`).Scan(&cpus))
+	require.Equal(t, 1, storages)
+	require.Equal(t, 0, cpus)
+}
+
+func TestSQLLaptopStoreSaveRollback(t *testing.T) {
+	t.Parallel()
+
+	db := openTestSQLDatabase(t)
+	store, err := NewSQLLaptopStore(db)
+	require.NoError(t, err)
+
+	// 最后一张表写入失败时，之前写入的行也要回滚
+	_, err = db.Exec(`DROP TABLE screens`)
+	require.NoError(t, err)
+
+	laptop := sample.NewLaptop()
+	require.Error(t, store.Save(laptop))
+
+	var count int
+	for _, table := range []string{"laptops", "cpus", "gpus", "storages"} {
+		require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM `+table).Scan(&count))
+		require.Zero(t, count, table)
+	}
+}
+
+func TestSQLLaptopStoreSearch(t *testing.T) {
+	t.Parallel()
+
+	store, err := NewSQLLaptopStore(openTestSQLDatabase(t))
+	require.NoError(t, err)
+	inMemory := NewInMemoryLaptopStore()
+
+	for i := 0; i < 200; i++ {
+		laptop := sample.NewLaptop()
+		require.NoError(t, store.Save(laptop))
+		require.NoError(t, inMemory.Save(laptop))
+	}
+
+	filters := []*pb.Filter{
+		{},
+		{MaxPriceUsd: 2500},
+		{MinCpuCores: 6, MinCpuGhz: 3},
+		{MinRam: &pb.Memory{Value: 32768, Unit: pb.Memory_MEGABYTE}},
+		{MaxPriceUsd: 3000, MinCpuCores: 4, MinCpuGhz: 2.5, MinRam: &pb.Memory{Value: 16, Unit: pb.Memory_GIGABYTE}},
+	}
+
+	search := func(store LaptopStore, filter *pb.Filter) []string {
+		var ids []string
+		err := store.Search(context.Background(), filter, func(laptop *pb.Laptop) error {
+			ids = append(ids, laptop.Id)
+			return nil
+		})
+		require.NoError(t, err)
+		sort.Strings(ids)
+		return ids
+	}
+
+	for _, filter := range filters {
+		expected := search(inMemory, filter)
+		require.NotEmpty(t, expected, "filter %v", filter)
+		require.Equal(t, expected, search(store, filter), "filter %v", filter)
+	}
+}
+
+func TestSQLLaptopStoreSearchWhileIterating(t *testing.T) {
+	t.Parallel()
+
+	store, err := NewSQLLaptopStore(openTestSQLDatabase(t))
+	require.NoError(t, err)
+	testSearchWhileIterating(t, store)
+}
+
+func TestSQLiteDSNEscapesFilename(t *testing.T) {
+	t.Parallel()
+
+	// 文件名中的特殊字符不能被当作DSN的参数
+	dir := filepath.Join(t.TempDir(), "what?_txlock=deferred#100% laptops")
+	require.NoError(t, os.Mkdir(dir, 0755))
+	filename := filepath.Join(dir, "laptops.db")
+
+	store, err := NewSQLLaptopStore(openSQLDatabase(t, filename))
+	require.NoError(t, err)
+	laptop := sample.NewLaptop()
+	require.NoError(t, store.Save(laptop))
+
+	found, err := store.Find(laptop.Id)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(laptop, found))
+	require.FileExists(t, filename)
+}
+
+func TestSQLMigrationsConcurrent(t *testing.T) {
+	t.Parallel()
+
+	// 模拟多个进程同时启动，每个都用自己的连接迁移同一个数据库
+	filename := filepath.Join(t.TempDir(), "laptops.db")
+	const processes = 8
+
+	errs := make(chan error, processes)
+	for i := 0; i < processes; i++ {
+		go func() {
+			db, err := sql.Open("sqlite3", SQLiteDSN(filename))
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer db.Close()
+
+			_, err = NewSQLLaptopStore(db)
+			errs <- err
+		}()
+	}
+	for i := 0; i < processes; i++ {
+		require.NoError(t, <-errs)
+	}
+
+	db := openSQLDatabase(t, filename)
+	var versions, laptops int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&versions))
+	require.Equal(t, len(sqlMigrations), versions)
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM laptops`).Scan(&laptops))
+}
+
+func TestSQLMigrations(t *testing.T) {
+	t.Parallel()
+
+	db := openTestSQLDatabase(t)
+	_, err := NewSQLLaptopStore(db)
+	require.NoError(t, err)
+
+	// 重复迁移不会重新执行已经应用的版本
+	_, err = NewSQLLaptopStore(db)
+	require.NoError(t, err)
+
+	versions := func() []int {
+		rows, err := db.Query(`SELECT version FROM schema_migrations ORDER BY version`)
+		require.NoError(t, err)
+		defer rows.Close()
+
+		var versions []int
+		for rows.Next() {
+			var version int
+			require.NoError(t, rows.Scan(&version))
+			versions = append(versions, version)
+		}
+		require.NoError(t, rows.Err())
+		return versions
+	}
+	require.Equal(t, []int{1, 2, 3}, versions())
+
+	// 失败的迁移整体回滚，不记录版本
+	failing := append(append([]sqlMigration(nil), sqlMigrations...), sqlMigration{
+		version:     len(sqlMigrations) + 1,
+		description: "broken migration",
+		statements: []string{
+			`CREATE TABLE broken (id TEXT)`,
+			`INSERT INTO missing_table VALUES (1)`,
+		},
+	})
+	err = migrate(context.Background(), db, failing)
+	require.Error(t, err)
+	require.Equal(t, []int{1, 2, 3}, versions())
+
+	_, err = db.Exec(`SELECT * FROM broken`)
+	require.Error(t, err)
+}
+
+func TestSQLLaptopStoreConcurrentWrites(t *testing.T) {
+	t.Parallel()
+
+	store, err := NewSQLLaptopStore(openTestSQLDatabase(t))
+	require.NoError(t, err)
+
+	const workers = 16
+	const laptopsPerWorker = 20
+
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			errs <- func() error {
+				for j := 0; j < laptopsPerWorker; j++ {
+					laptop := sample.NewLaptop()
+					if err := store.Save(laptop); err != nil {
+						return err
+					}
+					_, err := store.Update(laptop.Id, func(laptop *pb.Laptop) error {
+						laptop.Version++
+						return nil
+					})
+					if err != nil {
+						return err
+					}
+					if _, err = store.Find(laptop.Id); err != nil {
+						return err
+					}
+				}
+				return nil
+			}()
+		}()
+	}
+	for i := 0; i < workers; i++ {
+		require.NoError(t, <-errs)
+	}
+
+	count := 0
+	err = store.Search(context.Background(), &pb.Filter{}, func(laptop *pb.Laptop) error {
+		require.Equal(t, uint64(1), laptop.Version)
+		count++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, workers*laptopsPerWorker, count)
+}
+
+func TestSQLLaptopStorePrimaryKeyViolation(t *testing.T) {
+	t.Parallel()
+
+	db := openTestSQLDatabase(t)
+	store, err := NewSQLLaptopStore(db)
+	require.NoError(t, err)
+
+	laptop := sample.NewLaptop()
+	require.NoError(t, store.Save(laptop))
+
+	// 绕过 Save 中的检查，直接插入重复的ID
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	defer tx.Rollback()
+	err = insertLaptop(context.Background(), tx, laptop)
+	require.Error(t, err)
+	require.True(t, isPrimaryKeyViolation(err))
+
+	require.False(t, isPrimaryKeyViolation(ErrNotFound))
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// sqlMigration is a versioned change of the SQL schema, applied in one transaction
+type sqlMigration struct {
+	version     int
+	description string
+	statements  []string
+}
+
+// sqlMigrations are the migrations of the SQLLaptopStore schema, in version order.
+// Applied migrations must never change: add a new version instead.
+var sqlMigrations = []sqlMigration{
+	{
+		version:     1,
+		description: "create laptop tables",
+		statements: []string{
+			`CREATE TABLE laptops (
+				id                 TEXT PRIMARY KEY,
+				brand              TEXT NOT NULL,
+				name               TEXT NOT NULL,
+				ram_value          INTEGER,
+				ram_unit           TEXT,
+				ram_bytes          INTEGER,
+				keyboard_layout    TEXT,
+				keyboard_backlit   BOOLEAN,
+				weight_kg          REAL,
+				weight_lb          REAL,
+				price_usd          REAL NOT NULL,
+				release_year       INTEGER NOT NULL,
+				updated_at_seconds INTEGER,
+				updated_at_nanos   INTEGER
+			)`,
+			`CREATE TABLE cpus (
+				laptop_id      TEXT PRIMARY KEY REFERENCES laptops (id),
+				brand          TEXT NOT NULL,
+				name           TEXT NOT NULL,
+				number_cores   INTEGER NOT NULL,
+				number_threads INTEGER NOT NULL,
+				min_ghz        REAL NOT NULL,
+				max_ghz        REAL NOT NULL
+			)`,
+			`CREATE TABLE gpus (
+				laptop_id    TEXT NOT NULL REFERENCES laptops (id),
+				position     INTEGER NOT NULL,
+				brand        TEXT NOT NULL,
+				name         TEXT NOT NULL,
+				min_ghz      REAL NOT NULL,
+				max_ghz      REAL NOT NULL,
+				memory_value INTEGER,
+				memory_unit  TEXT,
+				PRIMARY KEY (laptop_id, position)
+			)`,
+			`CREATE TABLE storages (
+				laptop_id    TEXT NOT NULL REFERENCES laptops (id),
+				position     INTEGER NOT NULL,
+				driver       TEXT NOT NULL,
+				memory_value INTEGER,
+				memory_unit  TEXT,
+				PRIMARY KEY (laptop_id, position)
+			)`,
+			`CREATE TABLE screens (
+				laptop_id         TEXT PRIMARY KEY REFERENCES laptops (id),
+				size_inch         REAL NOT NULL,
+				resolution_width  INTEGER,
+				resolution_height INTEGER,
+				panel             TEXT NOT NULL,
+				multitouch        BOOLEAN NOT NULL
+			)`,
+		},
+	},
+	{
+		version:     2,
+		description: "index search columns",
+		statements: []string{
+			`CREATE INDEX laptops_price_usd ON laptops (price_usd)`,
+			`CREATE INDEX laptops_ram_bytes ON laptops (ram_bytes)`,
+			`CREATE INDEX cpus_number_cores ON cpus (number_cores)`,
+		},
+	},
+	{
+		version:     3,
+		description: "add laptop version",
+		statements: []string{
+			`ALTER TABLE laptops ADD COLUMN version INTEGER NOT NULL DEFAULT 0`,
+		},
+	},
+}
+
+// migrate applies the migrations that are not recorded in the schema_migrations table yet.
+// Several processes can migrate the same database at once, each version is applied by only one of them.
+func migrate(ctx context.Context, db *sql.DB, migrations []sqlMigration) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version     INTEGER PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at  TEXT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("cannot create schema_migrations table: %w", err)
+	}
+
+	var current int
+	err = db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current)
+	if err != nil {
+		return fmt.Errorf("cannot read schema version: %w", err)
+	}
+
+	for _, migration := range migrations {
+		if migration.version <= current {
+			continue
+		}
+		if err = applyMigration(ctx, db, migration); err != nil {
+			return fmt.Errorf("cannot apply migration %d (%s): %w", migration.version, migration.description, err)
+		}
+	}
+	return nil
+}
+
+// applyMigration applies migration in one transaction, unless another process applied it first
+func applyMigration(ctx context.Context, db *sql.DB, migration sqlMigration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 先插入版本号再执行迁移：插入会取得写锁，其他进程的迁移要等到这个事务结束。
+	// 版本号是主键，插入失败说明另一个进程已经提交了这个版本，跳过即可。
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, ?)`,
+		migration.version, migration.description, time.Now().UTC().Format(time.RFC3339),
+	)
+	if isPrimaryKeyViolation(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, statement := range migration.statements {
+		if _, err = tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Roles of the users
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// User contains user's information
+type User struct {
+	Username       string
+	HashedPassword string
+	Role           string
+}
+
+// NewUser returns a new user with a bcrypt hash of the password
+func NewUser(username string, password string, role string) (*User, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("cannot hash password: %w", err)
+	}
+
+	user := &User{
+		Username:       username,
+		HashedPassword: string(hashedPassword),
+		Role:           role,
+	}
+	return user, nil
+}
+
+// IsCorrectPassword checks if the provided password is correct or not
+func (user *User) IsCorrectPassword(password string) bool {
+	err := bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(password))
+	return err == nil
+}
+
+// Clone returns a clone of this user
+func (user *User) Clone() *User {
+	return &User{
+		Username:       user.Username,
+		HashedPassword: user.HashedPassword,
+		Role:           user.Role,
+	}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// UserStore is an interface to store users
+type UserStore interface {
+	// Save saves a user to the store
+	Save(user *User) error
+	// Find finds a user by username, it returns nil if the user doesn't exist
+	Find(username string) (*User, error)
+}
+
+// InMemoryUserStore stores users in memory
+type InMemoryUserStore struct {
+	mutex sync.RWMutex
+	users map[string]*User
+}
+
+// NewInMemoryUserStore returns a new in-memory user store
+func NewInMemoryUserStore() *InMemoryUserStore {
+	return &InMemoryUserStore{
+		users: make(map[string]*User),
+	}
+}
+
+// Save saves a user to the store
+func (store *InMemoryUserStore) Save(user *User) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.users[user.Username] != nil {
+		return ErrAlreadyExists
+	}
+
+	store.users[user.Username] = user.Clone()
+	return nil
+}
+
+// Find finds a user by username
+func (store *InMemoryUserStore) Find(username string) (*User, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	user := store.users[username]
+	if user == nil {
+		return nil, nil
+	}
+
+	return user.Clone(), nil
+}
+
+// UserConfig is a user to seed a user store with. The password can be given in
+// plain text, or as a bcrypt hash so the config file doesn't contain it.
+type UserConfig struct {
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password,omitempty"`
+	PasswordHash string `yaml:"password_hash,omitempty"`
+	Role         string `yaml:"role"`
+}
+
+// LoadUserConfigs reads the users of a YAML config file, such as:
+//
+//	users:
+//	  - username: admin1
+//	    password_hash: $2a$10$...
+//	    role: admin
+func LoadUserConfigs(filename string) ([]UserConfig, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read user config: %w", err)
+	}
+
+	config := struct {
+		Users []UserConfig `yaml:"users"`
+	}{}
+	if err = yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("cannot parse user config %s: %w", filename, err)
+	}
+	return config.Users, nil
+}
+
+// SeedUsers saves the configured users to the store
+func SeedUsers(store UserStore, configs []UserConfig) error {
+	for i, config := range configs {
+		user, err := config.newUser()
+		if err != nil {
+			return fmt.Errorf("invalid user %d: %w", i, err)
+		}
+		if err = store.Save(user); err != nil {
+			return fmt.Errorf("cannot save user %s: %w", user.Username, err)
+		}
+	}
+	return nil
+}
+
+func (config UserConfig) newUser() (*User, error) {
+	if config.Username == "" {
+		return nil, errors.New("username is required")
+	}
+	if config.Role == "" {
+		return nil, fmt.Errorf("role of user %s is required", config.Username)
+	}
+
+	switch {
+	case config.Password != "" && config.PasswordHash != "":
+		return nil, fmt.Errorf("user %s has both a password and a password hash", config.Username)
+	case config.Password != "":
+		return NewUser(config.Username, config.Password, config.Role)
+	case config.PasswordHash != "":
+		if _, err := bcrypt.Cost([]byte(config.PasswordHash)); err != nil {
+			return nil, fmt.Errorf("password hash of user %s is not a bcrypt hash: %w", config.Username, err)
+		}
+		return &User{
+			Username:       config.Username,
+			HashedPassword: config.PasswordHash,
+			Role:           config.Role,
+		}, nil
+	default:
+		return nil, fmt.Errorf("user %s has no password", config.Username)
+	}
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestUserPassword(t *testing.T) {
+	t.Parallel()
+
+	user, err := NewUser("admin1", "secret", RoleAdmin)
+	require.NoError(t, err)
+	require.NotEqual(t, "secret", user.HashedPassword)
+	require.True(t, user.IsCorrectPassword("secret"))
+	require.False(t, user.IsCorrectPassword("Secret"))
+	require.False(t, user.IsCorrectPassword(""))
+}
+
+func TestInMemoryUserStore(t *testing.T) {
+	t.Parallel()
+
+	store := NewInMemoryUserStore()
+	user, err := NewUser("user1", "secret", RoleUser)
+	require.NoError(t, err)
+
+	err = store.Save(user)
+	require.NoError(t, err)
+
+	err = store.Save(user)
+	require.ErrorIs(t, err, ErrAlreadyExists)
+
+	found, err := store.Find("user1")
+	require.NoError(t, err)
+	require.Equal(t, user, found)
+
+	// 返回的是拷贝，修改不影响存储的用户
+	found.Role = RoleAdmin
+	again, err := store.Find("user1")
+	require.NoError(t, err)
+	require.Equal(t, RoleUser, again.Role)
+
+	missing, err := store.Find("user2")
+	require.NoError(t, err)
+	require.Nil(t, missing)
+}
+
+func TestSeedUsers(t *testing.T) {
+	t.Parallel()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("hashed secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	filename := filepath.Join(t.TempDir(), "users.yaml")
+	config := "users:\n" +
+		"  - username: admin1\n" +
+		"    password: secret\n" +
+		"    role: admin\n" +
+		"  - username: user1\n" +
+		"    password_hash: " + string(hash) + "\n" +
+		"    role: user\n"
+	require.NoError(t, ioutil.WriteFile(filename, []byte(config), 0644))
+
+	configs, err := LoadUserConfigs(filename)
+	require.NoError(t, err)
+	require.Len(t, configs, 2)
+
+	store := NewInMemoryUserStore()
+	require.NoError(t, SeedUsers(store, configs))
+
+	admin, err := store.Find("admin1")
+	require.NoError(t, err)
+	require.Equal(t, RoleAdmin, admin.Role)
+	require.True(t, admin.IsCorrectPassword("secret"))
+
+	user, err := store.Find("user1")
+	require.NoError(t, err)
+	require.Equal(t, RoleUser, user.Role)
+	require.True(t, user.IsCorrectPassword("hashed secret"))
+
+	testCases := []struct {
+		name   string
+		config UserConfig
+	}{
+		{
+			name:   "no_username",
+			config: UserConfig{Password: "secret", Role: RoleUser},
+		},
+		{
+			name:   "no_role",
+			config: UserConfig{Username: "user2", Password: "secret"},
+		},
+		{
+			name:   "no_password",
+			config: UserConfig{Username: "user2", Role: RoleUser},
+		},
+		{
+			name:   "both_passwords",
+			config: UserConfig{Username: "user2", Password: "secret", PasswordHash: string(hash), Role: RoleUser},
+		},
+		{
+			name:   "invalid_hash",
+			config: UserConfig{Username: "user2", PasswordHash: "secret", Role: RoleUser},
+		},
+	}
+
+	for _, tc := range testCases {
+		err := SeedUsers(NewInMemoryUserStore(), []UserConfig{tc.config})
+		require.Error(t, err, tc.name)
+	}
+}
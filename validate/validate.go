@@ -0,0 +1,324 @@
+// Package validate checks the semantic validity of laptop messages.
+//
+// Unlike the protobuf runtime, which only checks that a message is well-formed,
+// the validators here report values that make no sense for a laptop, such as a
+// CPU whose max_ghz is lower than its min_ghz. Every violation is collected and
+// addressed by its field path (e.g. "gpus[0].memory.unit"), using the proto
+// field names, rather than stopping at the first error.
+package validate
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// Violation describes one invalid field of a message
+type Violation struct {
+	// Field is the path of the invalid field, e.g. "cpu.max_ghz" or "storages[1].driver",
+	// it is empty when the validated message itself is nil
+	Field string
+	// Description explains why the field is invalid
+	Description string
+}
+
+func (violation Violation) String() string {
+	if violation.Field == "" {
+		return violation.Description
+	}
+	return violation.Field + ": " + violation.Description
+}
+
+// Violations is a list of violations, it is returned as the error of a failed validation
+type Violations []Violation
+
+func (violations Violations) Error() string {
+	messages := make([]string, len(violations))
+	for i, violation := range violations {
+		messages[i] = violation.String()
+	}
+	return "invalid message: " + strings.Join(messages, "; ")
+}
+
+// Message validates message if it is one of the laptop messages, other messages are always valid.
+// The returned error is either nil or of type Violations.
+func Message(message proto.Message) error {
+	switch m := message.(type) {
+	case *pb.Laptop:
+		return Laptop(m)
+	case *pb.CPU:
+		return CPU(m)
+	case *pb.GPU:
+		return GPU(m)
+	case *pb.Memory:
+		return Memory(m)
+	case *pb.Storage:
+		return Storage(m)
+	case *pb.Screen:
+		return Screen(m)
+	case *pb.Keyboard:
+		return Keyboard(m)
+	default:
+		return nil
+	}
+}
+
+// Laptop validates a laptop and all of its components
+func Laptop(laptop *pb.Laptop) error {
+	v := &validator{}
+	v.laptop("", laptop)
+	return v.err()
+}
+
+// CPU validates a CPU
+func CPU(cpu *pb.CPU) error {
+	v := &validator{}
+	v.cpu("", cpu)
+	return v.err()
+}
+
+// GPU validates a GPU
+func GPU(gpu *pb.GPU) error {
+	v := &validator{}
+	v.gpu("", gpu)
+	return v.err()
+}
+
+// Memory validates a memory size
+func Memory(memory *pb.Memory) error {
+	v := &validator{}
+	v.memory("", memory)
+	return v.err()
+}
+
+// Storage validates a storage
+func Storage(storage *pb.Storage) error {
+	v := &validator{}
+	v.storage("", storage)
+	return v.err()
+}
+
+// Screen validates a screen
+func Screen(screen *pb.Screen) error {
+	v := &validator{}
+	v.screen("", screen)
+	return v.err()
+}
+
+// Keyboard validates a keyboard
+func Keyboard(keyboard *pb.Keyboard) error {
+	v := &validator{}
+	v.keyboard("", keyboard)
+	return v.err()
+}
+
+// validator collects violations while walking a message
+type validator struct {
+	violations Violations
+}
+
+func (v *validator) err() error {
+	if len(v.violations) == 0 {
+		return nil
+	}
+	return v.violations
+}
+
+func (v *validator) add(field string, format string, args ...interface{}) {
+	v.violations = append(v.violations, Violation{
+		Field:       field,
+		Description: fmt.Sprintf(format, args...),
+	})
+}
+
+// join appends a field name to a path, the root path is empty.
+// An empty field name refers to the element at path itself, e.g. "gpus[0]".
+func join(path string, field string) string {
+	if path == "" {
+		return field
+	}
+	if field == "" {
+		return path
+	}
+	return path + "." + field
+}
+
+func (v *validator) required(path string, field string, present bool) bool {
+	if !present {
+		v.add(join(path, field), "is required")
+	}
+	return present
+}
+
+func (v *validator) notEmpty(path string, field string, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.add(join(path, field), "must not be empty")
+	}
+}
+
+func (v *validator) positive(path string, field string, value float64) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		v.add(join(path, field), "must be a finite number")
+	} else if value <= 0 {
+		v.add(join(path, field), "must be greater than 0")
+	}
+}
+
+// frequency checks a min/max GHz pair
+func (v *validator) frequency(path string, minGhz float64, maxGhz float64) {
+	v.positive(path, "min_ghz", minGhz)
+	v.positive(path, "max_ghz", maxGhz)
+	if maxGhz < minGhz {
+		v.add(join(path, "max_ghz"), "must be greater than or equal to min_ghz (%v < %v)", maxGhz, minGhz)
+	}
+}
+
+func (v *validator) laptop(path string, laptop *pb.Laptop) {
+	if !v.required(path, "", laptop != nil) {
+		return
+	}
+
+	v.notEmpty(path, "brand", laptop.GetBrand())
+	v.notEmpty(path, "name", laptop.GetName())
+
+	if v.required(path, "cpu", laptop.GetCpu() != nil) {
+		v.cpu(join(path, "cpu"), laptop.GetCpu())
+	}
+
+	if v.required(path, "ram", laptop.GetRam() != nil) {
+		v.memory(join(path, "ram"), laptop.GetRam())
+	}
+
+	for i, gpu := range laptop.GetGpus() {
+		v.gpu(join(path, fmt.Sprintf("gpus[%d]", i)), gpu)
+	}
+
+	for i, storage := range laptop.GetStorages() {
+		v.storage(join(path, fmt.Sprintf("storages[%d]", i)), storage)
+	}
+
+	if v.required(path, "screen", laptop.GetScreen() != nil) {
+		v.screen(join(path, "screen"), laptop.GetScreen())
+	}
+
+	if v.required(path, "keyboard", laptop.GetKeyboard() != nil) {
+		v.keyboard(join(path, "keyboard"), laptop.GetKeyboard())
+	}
+
+	switch weight := laptop.GetWeight().(type) {
+	case *pb.Laptop_WeightKg:
+		v.positive(path, "weight_kg", weight.WeightKg)
+	case *pb.Laptop_WeightLb:
+		v.positive(path, "weight_lb", weight.WeightLb)
+	default:
+		v.add(join(path, "weight"), "one of weight_kg or weight_lb is required")
+	}
+
+	price := laptop.GetPriceUsd()
+	if math.IsNaN(price) || math.IsInf(price, 0) {
+		v.add(join(path, "price_usd"), "must be a finite number")
+	} else if price < 0 {
+		v.add(join(path, "price_usd"), "must not be negative")
+	}
+}
+
+func (v *validator) cpu(path string, cpu *pb.CPU) {
+	if !v.required(path, "", cpu != nil) {
+		return
+	}
+
+	v.notEmpty(path, "brand", cpu.GetBrand())
+	v.notEmpty(path, "name", cpu.GetName())
+
+	if cpu.GetNumberCores() == 0 {
+		v.add(join(path, "number_cores"), "must be greater than 0")
+	}
+	if cpu.GetNumberThreads() < cpu.GetNumberCores() {
+		v.add(join(path, "number_threads"), "must be greater than or equal to number_cores (%d < %d)",
+			cpu.GetNumberThreads(), cpu.GetNumberCores())
+	}
+
+	v.frequency(path, cpu.GetMinGhz(), cpu.GetMaxGhz())
+}
+
+func (v *validator) gpu(path string, gpu *pb.GPU) {
+	if !v.required(path, "", gpu != nil) {
+		return
+	}
+
+	v.notEmpty(path, "brand", gpu.GetBrand())
+	v.notEmpty(path, "name", gpu.GetName())
+	v.frequency(path, gpu.GetMinGhz(), gpu.GetMaxGhz())
+
+	if v.required(path, "memory", gpu.GetMemory() != nil) {
+		v.memory(join(path, "memory"), gpu.GetMemory())
+	}
+}
+
+func (v *validator) memory(path string, memory *pb.Memory) {
+	if !v.required(path, "", memory != nil) {
+		return
+	}
+
+	if memory.GetValue() == 0 {
+		v.add(join(path, "value"), "must be greater than 0")
+	}
+	if !isKnownEnum(int32(memory.GetUnit()), pb.Memory_Unit_name) {
+		v.add(join(path, "unit"), "must be a known unit, got %v", memory.GetUnit())
+	}
+}
+
+func (v *validator) storage(path string, storage *pb.Storage) {
+	if !v.required(path, "", storage != nil) {
+		return
+	}
+
+	if !isKnownEnum(int32(storage.GetDriver()), pb.Storage_Driver_name) {
+		v.add(join(path, "driver"), "must be a known driver, got %v", storage.GetDriver())
+	}
+
+	if v.required(path, "memory", storage.GetMemory() != nil) {
+		v.memory(join(path, "memory"), storage.GetMemory())
+	}
+}
+
+func (v *validator) screen(path string, screen *pb.Screen) {
+	if !v.required(path, "", screen != nil) {
+		return
+	}
+
+	v.positive(path, "size_inch", float64(screen.GetSizeInch()))
+
+	resolution := screen.GetResolution()
+	if v.required(path, "resolution", resolution != nil) {
+		if resolution.GetWidth() == 0 {
+			v.add(join(path, "resolution.width"), "must be greater than 0")
+		}
+		if resolution.GetHeight() == 0 {
+			v.add(join(path, "resolution.height"), "must be greater than 0")
+		}
+	}
+
+	if !isKnownEnum(int32(screen.GetPanel()), pb.Screen_Panel_name) {
+		v.add(join(path, "panel"), "must be a known panel, got %v", screen.GetPanel())
+	}
+}
+
+func (v *validator) keyboard(path string, keyboard *pb.Keyboard) {
+	if !v.required(path, "", keyboard != nil) {
+		return
+	}
+
+	if !isKnownEnum(int32(keyboard.GetLayout()), pb.Keyboard_Layout_name) {
+		v.add(join(path, "layout"), "must be a known layout, got %v", keyboard.GetLayout())
+	}
+}
+
+// isKnownEnum reports whether value is a defined, non-UNKNOWN enum value
+func isKnownEnum(value int32, names map[int32]string) bool {
+	name, ok := names[value]
+	return ok && value != 0 && name != "UNKNOWN"
+}
@@ -0,0 +1,110 @@
+package validate
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/Ruadgedy/pcbook/pb"
+	"github.com/Ruadgedy/pcbook/sample"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLaptopValid(t *testing.T) {
+	t.Parallel()
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, Laptop(sample.NewLaptop()))
+	}
+}
+
+func TestLaptopViolations(t *testing.T) {
+	t.Parallel()
+
+	laptop := sample.NewLaptop()
+	laptop.Brand = " "
+	laptop.Cpu.NumberCores = 8
+	laptop.Cpu.NumberThreads = 4
+	laptop.Cpu.MinGhz = 3.0
+	laptop.Cpu.MaxGhz = 2.5
+	laptop.Gpus[0].Memory.Unit = pb.Memory_UNKNOWN
+	laptop.Storages[1].Driver = pb.Storage_UNKNOWN
+	laptop.Storages[1].Memory = nil
+	laptop.Screen.SizeInch = 0
+	laptop.Screen.Resolution.Height = 0
+	laptop.Keyboard.Layout = pb.Keyboard_UNKNOWN
+	laptop.Weight = nil
+	laptop.PriceUsd = -1
+
+	err := Laptop(laptop)
+	require.Error(t, err)
+
+	violations, ok := err.(Violations)
+	require.True(t, ok)
+
+	fields := make([]string, len(violations))
+	for i, violation := range violations {
+		fields[i] = violation.Field
+	}
+	require.Equal(t, []string{
+		"brand",
+		"cpu.number_threads",
+		"cpu.max_ghz",
+		"gpus[0].memory.unit",
+		"storages[1].driver",
+		"storages[1].memory",
+		"screen.size_inch",
+		"screen.resolution.height",
+		"keyboard.layout",
+		"weight",
+		"price_usd",
+	}, fields)
+}
+
+func TestLaptopMissingComponents(t *testing.T) {
+	t.Parallel()
+
+	err := Laptop(&pb.Laptop{
+		Brand:  "Apple",
+		Name:   "Macbook Pro",
+		Weight: &pb.Laptop_WeightLb{WeightLb: math.NaN()},
+	})
+	require.Error(t, err)
+
+	fields := []string{}
+	for _, violation := range err.(Violations) {
+		fields = append(fields, violation.Field)
+	}
+	require.Equal(t, []string{"cpu", "ram", "screen", "keyboard", "weight_lb"}, fields)
+
+	err = Laptop(nil)
+	require.EqualError(t, err, "invalid message: is required")
+}
+
+func TestLaptopNilElements(t *testing.T) {
+	t.Parallel()
+
+	laptop := sample.NewLaptop()
+	laptop.Gpus = append(laptop.Gpus, nil)
+	laptop.Storages = []*pb.Storage{nil, laptop.Storages[0]}
+
+	err := Laptop(laptop)
+	require.EqualError(t, err, fmt.Sprintf(
+		"invalid message: gpus[%d]: is required; storages[0]: is required", len(laptop.Gpus)-1))
+}
+
+func TestMessage(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, Message(sample.NewCPU()))
+	require.NoError(t, Message(&pb.Filter{}))
+
+	err := Message(&pb.Memory{Value: 0, Unit: pb.Memory_GIGABYTE})
+	require.EqualError(t, err, "invalid message: value: must be greater than 0")
+
+	err = Message(&pb.Screen_Resolution{})
+	require.NoError(t, err)
+
+	err = Storage(&pb.Storage{Driver: pb.Storage_Driver(42), Memory: sample.NewRAM()})
+	require.EqualError(t, err, "invalid message: driver: must be a known driver, got 42")
+}